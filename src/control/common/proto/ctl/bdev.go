@@ -0,0 +1,36 @@
+//
+// (C) Copyright 2021 Intel Corporation.
+//
+// SPDX-License-Identifier: BSD-2-Clause-Patent
+//
+
+// Package ctl holds the control-plane request/response message types shared
+// between the gRPC service definitions and their handlers.
+package ctl
+
+// BdevAttachReq requests that a live NVMe controller be attached to a
+// running engine's SPDK instance via JSON-RPC, for a device that wasn't
+// part of the config generated at engine start. EngineIdx addresses the
+// target engine by its configured index (as used by
+// EngineHarness.GetEngineInstance), not its DAOS rank, since a rank may not
+// be assigned yet when this request arrives.
+type BdevAttachReq struct {
+	EngineIdx uint32
+	TierIdx   uint32
+	PciAddr   string
+}
+
+// BdevAttachResp is returned once the controller has been attached.
+type BdevAttachResp struct{}
+
+// BdevDetachReq requests that a live NVMe controller be detached from a
+// running engine's SPDK instance via JSON-RPC. EngineIdx addresses the
+// target engine by its configured index, not its DAOS rank; see
+// BdevAttachReq.
+type BdevDetachReq struct {
+	EngineIdx uint32
+	Name      string
+}
+
+// BdevDetachResp is returned once the controller has been detached.
+type BdevDetachResp struct{}
@@ -0,0 +1,138 @@
+//
+// (C) Copyright 2021 Intel Corporation.
+//
+// SPDX-License-Identifier: BSD-2-Clause-Patent
+//
+
+// Package events defines the RAS event types published over PubSub as
+// engines and the control plane react to storage and membership changes.
+package events
+
+import (
+	"context"
+	"sync"
+)
+
+// RASType classifies a RASEvent for subscription filtering.
+type RASType uint32
+
+const (
+	// RASTypeAny matches every event, regardless of ID.
+	RASTypeAny RASType = iota
+	// RASTypeStateChange matches events describing a state transition
+	// that the MS leader may need to act on (rank death, smd device
+	// faulted/new, hotplug insert/remove, ...).
+	RASTypeStateChange
+)
+
+// RASID identifies a specific kind of RASEvent.
+type RASID uint32
+
+const (
+	// RASSwimRankDead fires when SWIM detects a rank is unreachable.
+	RASSwimRankDead RASID = iota + 1
+	// RASBdevHotplugInserted fires when SPDK reports a new NVMe
+	// controller attached via hotplug.
+	RASBdevHotplugInserted
+	// RASBdevHotplugRemoved fires when SPDK reports an NVMe controller
+	// hot-removed.
+	RASBdevHotplugRemoved
+	// RASBdevSRIOVReconfigureFailed fires when a PF's VF count could not
+	// be reconfigured, or its VFs enumerated, during prepare.
+	RASBdevSRIOVReconfigureFailed
+)
+
+// RASEvent describes a single actionable event raised on a host.
+type RASEvent struct {
+	ID       RASID
+	Type     RASType
+	Hostname string
+	Rank     uint32
+	Msg      string
+	PCIAddr  string
+}
+
+// NewBdevHotplugEvent describes an NVMe controller attaching or detaching
+// via hotplug, forwarded to the MS leader so it can mark the corresponding
+// smd device faulty/new.
+func NewBdevHotplugEvent(hostname, pciAddr string, inserted bool) *RASEvent {
+	id := RASBdevHotplugRemoved
+	msg := "NVMe controller removed"
+	if inserted {
+		id = RASBdevHotplugInserted
+		msg = "NVMe controller attached"
+	}
+
+	return &RASEvent{
+		ID:       id,
+		Type:     RASTypeStateChange,
+		Hostname: hostname,
+		PCIAddr:  pciAddr,
+		Msg:      msg,
+	}
+}
+
+// NewSRIOVReconfigureFailedEvent describes a failure to bring a PF's VF
+// count in line with its configured stanza, or to enumerate its VFs
+// afterwards, so cluster-wide orchestration can react.
+func NewSRIOVReconfigureFailedEvent(hostname, pf string, cause error) *RASEvent {
+	return &RASEvent{
+		ID:       RASBdevSRIOVReconfigureFailed,
+		Type:     RASTypeStateChange,
+		Hostname: hostname,
+		PCIAddr:  pf,
+		Msg:      "SR-IOV reconfigure failed: " + cause.Error(),
+	}
+}
+
+// Handler reacts to a published RASEvent.
+type Handler interface {
+	OnEvent(ctx context.Context, evt *RASEvent)
+}
+
+// HandlerFunc adapts a plain function to the Handler interface.
+type HandlerFunc func(ctx context.Context, evt *RASEvent)
+
+// OnEvent implements Handler.
+func (f HandlerFunc) OnEvent(ctx context.Context, evt *RASEvent) { f(ctx, evt) }
+
+// PubSub fans published events out to the handlers subscribed to their
+// RASType.
+type PubSub struct {
+	mu       sync.RWMutex
+	handlers map[RASType][]Handler
+}
+
+// NewPubSub returns a ready-to-use PubSub.
+func NewPubSub() *PubSub {
+	return &PubSub{handlers: make(map[RASType][]Handler)}
+}
+
+// Subscribe registers handler to be invoked for events matching rasType.
+func (ps *PubSub) Subscribe(rasType RASType, handler Handler) {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	ps.handlers[rasType] = append(ps.handlers[rasType], handler)
+}
+
+// Reset clears every subscription, used on leadership change before the
+// caller re-subscribes the appropriate handlers for the new role.
+func (ps *PubSub) Reset() {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+	ps.handlers = make(map[RASType][]Handler)
+}
+
+// Publish invokes every handler subscribed to evt.Type or RASTypeAny.
+func (ps *PubSub) Publish(evt *RASEvent) {
+	ps.mu.RLock()
+	defer ps.mu.RUnlock()
+	for _, h := range ps.handlers[RASTypeAny] {
+		h.OnEvent(context.Background(), evt)
+	}
+	if evt.Type != RASTypeAny {
+		for _, h := range ps.handlers[evt.Type] {
+			h.OnEvent(context.Background(), evt)
+		}
+	}
+}
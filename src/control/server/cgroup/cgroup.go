@@ -0,0 +1,488 @@
+//
+// (C) Copyright 2021 Intel Corporation.
+//
+// SPDX-License-Identifier: BSD-2-Clause-Patent
+//
+
+// Package cgroup places engine processes into a dedicated cgroup scope and
+// applies CPU/memory/IO/PID limits derived from engine.Config, giving
+// operators real isolation between co-located engines (and the control
+// plane) on dense NUMA hosts. It prefers cgroup v2 and falls back to the
+// equivalent v1 controllers when only those are mounted.
+package cgroup
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/daos-stack/daos/src/control/logging"
+)
+
+const (
+	// DefaultParent is the cgroup v2 slice under which per-engine scopes
+	// are created when engine.Config.CgroupParent is unset. On a v1 host
+	// the same relative subtree ("daos.slice/engine-N.scope") is created
+	// under each controller's own hierarchy instead.
+	DefaultParent = "/sys/fs/cgroup/daos.slice"
+
+	cgroupV2Root = "/sys/fs/cgroup"
+	cgroupV1Root = "/sys/fs/cgroup"
+)
+
+// v1Controllers are the separate v1 hierarchies touched by Apply/Remove, each
+// mounted independently (e.g. /sys/fs/cgroup/cpuset/...), unlike the single
+// v2 unified mount.
+var v1Controllers = []string{"cpuset", "cpuacct", "memory", "blkio", "pids"}
+
+// v2Controllers are the unified-hierarchy controllers Limits can touch. A v2
+// controller's interface files (cpuset.cpus, memory.max, ...) only appear in
+// a cgroup once every ancestor up to the root has it enabled in its own
+// cgroup.subtree_control; a freshly created scope under a parent that never
+// enabled them would otherwise have none of these files at all.
+var v2Controllers = []string{"cpuset", "memory", "io", "pids"}
+
+// Limits are the resource controls applied to an engine's cgroup scope. Zero
+// values leave the corresponding controller untouched (i.e. inherited from
+// the parent).
+type Limits struct {
+	// CPUSet is a cpulist (e.g. "0-15") written to cpuset.cpus, typically
+	// derived from the engine's pinned NUMA node.
+	CPUSet string
+	// MemsAllowed is a nodelist written to cpuset.mems, paired with CPUSet.
+	MemsAllowed string
+	// MemoryMax is the memory ceiling in bytes.
+	MemoryMax uint64
+	// IOWeight is the relative IO weight (10-1000) for io.weight/blkio.weight.
+	IOWeight uint64
+	// PidsMax caps the number of tasks/threads the engine may fork.
+	PidsMax uint64
+}
+
+// Usage reports current resource consumption for an engine's cgroup scope,
+// as surfaced through the Prometheus exporter (engine_cpu_usage_seconds,
+// engine_memory_bytes, engine_io_bytes).
+type Usage struct {
+	CPUUsageSecs float64
+	MemoryBytes  uint64
+	IOBytes      uint64
+}
+
+// Manager creates and tears down per-engine cgroup scopes under a
+// configurable parent, preferring cgroup v2 and falling back to v1.
+type Manager struct {
+	log    logging.Logger
+	parent string
+	isV2   bool
+}
+
+// NewManager returns a Manager rooted at parent (DefaultParent if empty),
+// detecting whether the host has cgroup v2 (unified hierarchy) mounted.
+func NewManager(log logging.Logger, parent string) *Manager {
+	if parent == "" {
+		parent = DefaultParent
+	}
+
+	return &Manager{
+		log:    log,
+		parent: parent,
+		isV2:   isUnifiedHierarchy(),
+	}
+}
+
+// isUnifiedHierarchy returns true if cgroup v2's single mount is in use,
+// identified by the presence of cgroup.controllers at the root.
+func isUnifiedHierarchy() bool {
+	_, err := os.Stat(filepath.Join(cgroupV2Root, "cgroup.controllers"))
+	return err == nil
+}
+
+// ScopeName returns the name of the scope directory for a given engine rank,
+// e.g. "engine-0.scope".
+func ScopeName(rank uint32) string {
+	return fmt.Sprintf("engine-%d.scope", rank)
+}
+
+func (m *Manager) scopePath(rank uint32) string {
+	return filepath.Join(m.parent, ScopeName(rank))
+}
+
+// relParent returns the configured parent relative to the v2 unified mount,
+// used as the equivalent subtree name under each v1 controller hierarchy
+// (e.g. parent "/sys/fs/cgroup/daos.slice" becomes "daos.slice").
+func (m *Manager) relParent() string {
+	rel := strings.TrimPrefix(m.parent, cgroupV2Root)
+	return strings.TrimPrefix(rel, string(filepath.Separator))
+}
+
+// v1ScopePath returns the absolute path of rank's scope directory under
+// controller's own v1 hierarchy, e.g. "/sys/fs/cgroup/cpuset/daos.slice/engine-0.scope".
+func (m *Manager) v1ScopePath(controller string, rank uint32) string {
+	return filepath.Join(cgroupV1Root, controller, m.relParent(), ScopeName(rank))
+}
+
+// Apply creates (if necessary) the cgroup scope for rank, moves pid into it
+// and applies lim. It is safe to call repeatedly; existing limits are
+// overwritten.
+func (m *Manager) Apply(rank uint32, pid int, lim Limits) error {
+	if !m.isV2 {
+		return m.applyV1(rank, pid, lim)
+	}
+
+	if err := enableSubtreeControllers(m.parent); err != nil {
+		return err
+	}
+
+	scope := m.scopePath(rank)
+	if err := os.MkdirAll(scope, 0755); err != nil {
+		return errors.Wrapf(err, "create cgroup scope %s", scope)
+	}
+
+	if err := m.applyV2(scope, lim); err != nil {
+		return err
+	}
+
+	if err := m.writePID(scope, pid); err != nil {
+		return err
+	}
+
+	m.log.Debugf("cgroup: engine rank %d (pid %d) placed in %s", rank, pid, scope)
+
+	return nil
+}
+
+// writePID adds pid as a member task of the scope, using the v2
+// cgroup.procs file name which also exists (with the same semantics) under
+// every v1 controller directory.
+func (m *Manager) writePID(scope string, pid int) error {
+	if err := writeFile(filepath.Join(scope, "cgroup.procs"), strconv.Itoa(pid)); err != nil {
+		return errors.Wrapf(err, "add pid %d to cgroup %s", pid, scope)
+	}
+
+	return nil
+}
+
+// joinV1 creates rank's scope directory under controller's v1 hierarchy, if
+// it doesn't already exist, and adds pid as one of its tasks.
+func (m *Manager) joinV1(rank uint32, pid int, controller string) error {
+	dir := m.v1ScopePath(controller, rank)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return errors.Wrapf(err, "create v1 %s cgroup %s", controller, dir)
+	}
+
+	return m.writePID(dir, pid)
+}
+
+func (m *Manager) applyV2(scope string, lim Limits) error {
+	if lim.CPUSet != "" {
+		if err := writeFile(filepath.Join(scope, "cpuset.cpus"), lim.CPUSet); err != nil {
+			return errors.Wrap(err, "set cpuset.cpus")
+		}
+	}
+	if lim.MemsAllowed != "" {
+		if err := writeFile(filepath.Join(scope, "cpuset.mems"), lim.MemsAllowed); err != nil {
+			return errors.Wrap(err, "set cpuset.mems")
+		}
+	}
+	if lim.MemoryMax > 0 {
+		if err := writeFile(filepath.Join(scope, "memory.max"), strconv.FormatUint(lim.MemoryMax, 10)); err != nil {
+			return errors.Wrap(err, "set memory.max")
+		}
+	}
+	if lim.IOWeight > 0 {
+		if err := writeFile(filepath.Join(scope, "io.weight"), strconv.FormatUint(lim.IOWeight, 10)); err != nil {
+			return errors.Wrap(err, "set io.weight")
+		}
+	}
+	if lim.PidsMax > 0 {
+		if err := writeFile(filepath.Join(scope, "pids.max"), strconv.FormatUint(lim.PidsMax, 10)); err != nil {
+			return errors.Wrap(err, "set pids.max")
+		}
+	}
+
+	return nil
+}
+
+// applyV1 places pid into the equivalent per-subsystem v1 controller
+// directories (cpuset, memory, blkio, pids), each a separate hierarchy
+// mounted independently of the others, and applies lim to each. A pid must
+// be added as a task of every controller's group it is to be limited by, so
+// each touched controller gets its own mkdir + cgroup.procs write. cpuacct
+// carries no limit of its own - it's joined unconditionally purely so
+// usageV1 has a populated cpuacct.usage to read back later.
+func (m *Manager) applyV1(rank uint32, pid int, lim Limits) error {
+	if err := m.joinV1(rank, pid, "cpuacct"); err != nil {
+		return err
+	}
+
+	type write struct {
+		controller string
+		file       string
+		val        string
+	}
+
+	var writes []write
+	if lim.CPUSet != "" {
+		writes = append(writes, write{"cpuset", "cpuset.cpus", lim.CPUSet})
+	}
+	if lim.MemsAllowed != "" {
+		writes = append(writes, write{"cpuset", "cpuset.mems", lim.MemsAllowed})
+	}
+	if lim.MemoryMax > 0 {
+		writes = append(writes, write{"memory", "memory.limit_in_bytes", strconv.FormatUint(lim.MemoryMax, 10)})
+	}
+	if lim.IOWeight > 0 {
+		writes = append(writes, write{"blkio", "blkio.weight", strconv.FormatUint(lim.IOWeight, 10)})
+	}
+	if lim.PidsMax > 0 {
+		writes = append(writes, write{"pids", "pids.max", strconv.FormatUint(lim.PidsMax, 10)})
+	}
+
+	joined := make(map[string]bool)
+	for _, w := range writes {
+		dir := m.v1ScopePath(w.controller, rank)
+		if !joined[w.controller] {
+			if err := m.joinV1(rank, pid, w.controller); err != nil {
+				return err
+			}
+			joined[w.controller] = true
+		}
+
+		if err := writeFile(filepath.Join(dir, w.file), w.val); err != nil {
+			return errors.Wrapf(err, "set %s (v1)", w.file)
+		}
+	}
+
+	m.log.Debugf("cgroup: engine rank %d (pid %d) placed in v1 controllers under %s", rank, pid, m.relParent())
+
+	return nil
+}
+
+// Remove deletes the cgroup scope for rank on engine exit. ENOENT is not
+// treated as an error, as the scope may never have been created (e.g. Apply
+// failed, or the engine never started).
+func (m *Manager) Remove(rank uint32) error {
+	if !m.isV2 {
+		var firstErr error
+		for _, controller := range v1Controllers {
+			dir := m.v1ScopePath(controller, rank)
+			if err := os.Remove(dir); err != nil && !os.IsNotExist(err) && firstErr == nil {
+				firstErr = errors.Wrapf(err, "remove v1 %s cgroup %s", controller, dir)
+			}
+		}
+
+		return firstErr
+	}
+
+	scope := m.scopePath(rank)
+	if err := os.Remove(scope); err != nil && !os.IsNotExist(err) {
+		return errors.Wrapf(err, "remove cgroup scope %s", scope)
+	}
+
+	return nil
+}
+
+// Usage reads current CPU/memory/IO accounting for rank's scope, from the
+// unified hierarchy's accounting files or, on a v1 host, from the
+// cpuacct/memory/blkio controllers' own accounting files. Returns an error if
+// rank's scope doesn't exist (not yet created by Apply, or already removed).
+func (m *Manager) Usage(rank uint32) (*Usage, error) {
+	dir := m.scopePath(rank)
+	if !m.isV2 {
+		dir = m.v1ScopePath("cpuacct", rank)
+	}
+	if _, err := os.Stat(dir); err != nil {
+		return nil, errors.Wrapf(err, "cgroup scope for engine %d", rank)
+	}
+
+	if !m.isV2 {
+		return m.usageV1(rank)
+	}
+
+	scope := m.scopePath(rank)
+
+	u := &Usage{}
+	if mem, err := readUint(filepath.Join(scope, "memory.current")); err == nil {
+		u.MemoryBytes = mem
+	}
+	if usec, err := readKeyedUint(filepath.Join(scope, "cpu.stat"), "usage_usec"); err == nil {
+		u.CPUUsageSecs = float64(usec) / 1e6
+	}
+	if io, err := readIOStatBytes(filepath.Join(scope, "io.stat")); err == nil {
+		u.IOBytes = io
+	}
+
+	return u, nil
+}
+
+func (m *Manager) usageV1(rank uint32) (*Usage, error) {
+	u := &Usage{}
+	if mem, err := readUint(filepath.Join(m.v1ScopePath("memory", rank), "memory.usage_in_bytes")); err == nil {
+		u.MemoryBytes = mem
+	}
+	if ns, err := readUint(filepath.Join(m.v1ScopePath("cpuacct", rank), "cpuacct.usage")); err == nil {
+		u.CPUUsageSecs = float64(ns) / 1e9
+	}
+	if io, err := readBlkioTotalBytes(filepath.Join(m.v1ScopePath("blkio", rank), "blkio.throttle.io_service_bytes")); err == nil {
+		u.IOBytes = io
+	}
+
+	return u, nil
+}
+
+// enableSubtreeControllers walks from the v2 unified mount down to (and
+// including) path, enabling every controller in v2Controllers that's
+// available at each level's cgroup.subtree_control. A descendant only gets a
+// controller's interface files once all of its ancestors have delegated that
+// controller down to it, so this must run before a child scope is created
+// under path.
+func enableSubtreeControllers(path string) error {
+	rel, err := filepath.Rel(cgroupV2Root, path)
+	if err != nil {
+		return errors.Wrapf(err, "resolve %s relative to %s", path, cgroupV2Root)
+	}
+
+	dir := cgroupV2Root
+	if err := enableControllersAt(dir); err != nil {
+		return err
+	}
+
+	for _, part := range strings.Split(rel, string(filepath.Separator)) {
+		dir = filepath.Join(dir, part)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return errors.Wrapf(err, "create cgroup dir %s", dir)
+		}
+		if err := enableControllersAt(dir); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// enableControllersAt enables every v2Controllers entry available in dir's
+// cgroup.controllers that isn't already enabled in dir's
+// cgroup.subtree_control, so dir's children can see (and be limited by) it.
+func enableControllersAt(dir string) error {
+	available, err := readControllerList(filepath.Join(dir, "cgroup.controllers"))
+	if err != nil {
+		return errors.Wrapf(err, "read available controllers in %s", dir)
+	}
+
+	subtreeControl := filepath.Join(dir, "cgroup.subtree_control")
+	enabled, err := readControllerList(subtreeControl)
+	if err != nil {
+		return errors.Wrapf(err, "read enabled controllers in %s", dir)
+	}
+
+	for _, ctrl := range v2Controllers {
+		if !containsController(enabled, ctrl) && containsController(available, ctrl) {
+			if err := writeFile(subtreeControl, "+"+ctrl); err != nil {
+				return errors.Wrapf(err, "enable %s controller in %s", ctrl, dir)
+			}
+		}
+	}
+
+	return nil
+}
+
+func readControllerList(path string) ([]string, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return strings.Fields(string(b)), nil
+}
+
+func containsController(list []string, ctrl string) bool {
+	for _, c := range list {
+		if c == ctrl {
+			return true
+		}
+	}
+
+	return false
+}
+
+func writeFile(path, val string) error {
+	return ioutil.WriteFile(path, []byte(val), 0644)
+}
+
+func readUint(path string) (uint64, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.ParseUint(strings.TrimSpace(string(b)), 10, 64)
+}
+
+// readKeyedUint reads a "key value" pair file (e.g. cgroup v2's cpu.stat)
+// and returns the value for key.
+func readKeyedUint(path, key string) (uint64, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, line := range strings.Split(string(b), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == key {
+			return strconv.ParseUint(fields[1], 10, 64)
+		}
+	}
+
+	return 0, errors.Errorf("%s: key %q not found", path, key)
+}
+
+// readIOStatBytes sums the rbytes/wbytes fields of cgroup v2's io.stat,
+// which lists one "<major>:<minor> key=value ..." line per device.
+func readIOStatBytes(path string) (uint64, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+
+	var total uint64
+	for _, line := range strings.Split(string(b), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		for _, kv := range fields[1:] {
+			k, v, ok := strings.Cut(kv, "=")
+			if !ok || (k != "rbytes" && k != "wbytes") {
+				continue
+			}
+			if n, err := strconv.ParseUint(v, 10, 64); err == nil {
+				total += n
+			}
+		}
+	}
+
+	return total, nil
+}
+
+// readBlkioTotalBytes reads the grand total line ("Total <n>") of a v1
+// blkio.throttle.io_service_bytes file.
+func readBlkioTotalBytes(path string) (uint64, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, line := range strings.Split(string(b), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == "Total" {
+			return strconv.ParseUint(fields[1], 10, 64)
+		}
+	}
+
+	return 0, errors.Errorf("%s: no Total line found", path)
+}
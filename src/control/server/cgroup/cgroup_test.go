@@ -0,0 +1,143 @@
+//
+// (C) Copyright 2021 Intel Corporation.
+//
+// SPDX-License-Identifier: BSD-2-Clause-Patent
+//
+
+package cgroup
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestManagerV1ScopePath(t *testing.T) {
+	m := &Manager{parent: DefaultParent}
+
+	got := m.v1ScopePath("cpuset", 3)
+	want := filepath.Join(cgroupV1Root, "cpuset", "daos.slice", "engine-3.scope")
+	if got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+}
+
+func TestManagerRelParent(t *testing.T) {
+	for _, tc := range []struct {
+		parent string
+		want   string
+	}{
+		{DefaultParent, "daos.slice"},
+		{"/sys/fs/cgroup/custom.slice", "custom.slice"},
+	} {
+		m := &Manager{parent: tc.parent}
+		if got := m.relParent(); got != tc.want {
+			t.Fatalf("parent %s: got %s, want %s", tc.parent, got, tc.want)
+		}
+	}
+}
+
+func TestReadKeyedUint(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "cpu.stat")
+	writeTestFile(t, path, "usage_usec 123456\nuser_usec 100000\nsystem_usec 23456\n")
+
+	got, err := readKeyedUint(path, "usage_usec")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != 123456 {
+		t.Fatalf("got %d, want 123456", got)
+	}
+
+	if _, err := readKeyedUint(path, "missing_key"); err == nil {
+		t.Fatal("expected error for missing key")
+	}
+}
+
+func TestReadIOStatBytes(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "io.stat")
+	writeTestFile(t, path, "8:0 rbytes=1000 wbytes=2000 rios=1 wios=1\n254:1 rbytes=500 wbytes=0 rios=1 wios=0\n")
+
+	got, err := readIOStatBytes(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := uint64(3500); got != want {
+		t.Fatalf("got %d, want %d", got, want)
+	}
+}
+
+func TestReadBlkioTotalBytes(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "blkio.throttle.io_service_bytes")
+	writeTestFile(t, path, "8:0 Read 1000\n8:0 Write 2000\n8:0 Total 3000\nTotal 3000\n")
+
+	got, err := readBlkioTotalBytes(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := uint64(3000); got != want {
+		t.Fatalf("got %d, want %d", got, want)
+	}
+}
+
+func TestReadControllerList(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "cgroup.controllers")
+	writeTestFile(t, path, "cpuset memory io pids\n")
+
+	got, err := readControllerList(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"cpuset", "memory", "io", "pids"}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	}
+}
+
+func TestContainsController(t *testing.T) {
+	list := []string{"cpuset", "memory"}
+	if !containsController(list, "cpuset") {
+		t.Fatal("expected cpuset to be found")
+	}
+	if containsController(list, "io") {
+		t.Fatal("expected io to not be found")
+	}
+}
+
+func TestEnableControllersAt_alreadyEnabled(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "cgroup.controllers"), "cpuset memory io pids\n")
+	writeTestFile(t, filepath.Join(dir, "cgroup.subtree_control"), "cpuset memory io pids\n")
+
+	// Every needed controller is already enabled, so this must not attempt
+	// any write to cgroup.subtree_control (which a real kernel file would
+	// reject as a no-op re-enable in some cases).
+	if err := enableControllersAt(dir); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// Any write would have replaced the file's contents with a "+<ctrl>"
+	// line, so an unchanged list shows none was attempted.
+	got, err := readControllerList(filepath.Join(dir, "cgroup.subtree_control"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 4 || got[0] != "cpuset" || got[3] != "pids" {
+		t.Fatalf("cgroup.subtree_control was rewritten: %v", got)
+	}
+}
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := writeFile(path, content); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,89 @@
+//
+// (C) Copyright 2021 Intel Corporation.
+//
+// SPDX-License-Identifier: BSD-2-Clause-Patent
+//
+
+// Package engine holds the per-engine configuration consumed when starting
+// and supervising a single data-plane engine process.
+package engine
+
+import "github.com/daos-stack/daos/src/control/server/storage"
+
+// FabricConfig describes the fabric provider and interface an engine binds
+// to for CaRT/Mercury communication.
+type FabricConfig struct {
+	Provider  string
+	Interface string
+}
+
+// ScmConfig describes an engine's SCM storage.
+type ScmConfig struct {
+	MountPoint string
+}
+
+// StorageConfig groups an engine's SCM and bdev storage configuration. Bdev
+// is a BdevTier (rather than a single BdevConfig) so an engine can configure
+// more than one bdev tier, addressed by index via EngineInstance.bdevTier.
+type StorageConfig struct {
+	SCM  ScmConfig
+	Bdev storage.BdevTier
+}
+
+// CgroupConfig controls whether, and how, an engine process is placed into
+// a dedicated cgroup scope with resource limits applied.
+type CgroupConfig struct {
+	// Enabled opts the engine into cgroup isolation.
+	Enabled bool
+	// Parent is the cgroup (v2 unified, or v1 per-controller) directory
+	// under which the engine's scope is created. Defaults to
+	// cgroup.DefaultParent when empty.
+	Parent string
+	// CPUSet is a cpulist (e.g. "0-15") written to cpuset.cpus. Defaults
+	// to the engine's pinned NUMA node when empty.
+	CPUSet string
+	// MemoryMax is the memory ceiling in bytes. Unlimited when 0.
+	MemoryMax uint64
+	// IOWeight is the relative IO weight (10-1000). Default when 0.
+	IOWeight uint64
+	// PidsMax caps the number of tasks/threads the engine may fork.
+	// Unlimited when 0.
+	PidsMax uint64
+}
+
+// Config holds the parameters needed to start and supervise a single
+// engine.
+type Config struct {
+	Fabric  FabricConfig
+	Storage StorageConfig
+	Cgroup  CgroupConfig
+
+	envVars []string
+}
+
+// HasEnvVar returns true if the config already carries an environment
+// variable assignment for key.
+func (c *Config) HasEnvVar(key string) bool {
+	prefix := key + "="
+	for _, e := range c.envVars {
+		if len(e) >= len(prefix) && e[:len(prefix)] == prefix {
+			return true
+		}
+	}
+
+	return false
+}
+
+// WithEnvVars appends the given "key=value" assignments to the engine's
+// environment, returning the config for chaining.
+func (c *Config) WithEnvVars(newVars ...string) *Config {
+	c.envVars = append(c.envVars, newVars...)
+
+	return c
+}
+
+// EnvVars returns the full set of "key=value" environment assignments
+// collected via WithEnvVars.
+func (c *Config) EnvVars() []string {
+	return c.envVars
+}
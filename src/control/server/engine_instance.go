@@ -0,0 +1,216 @@
+//
+// (C) Copyright 2021 Intel Corporation.
+//
+// SPDX-License-Identifier: BSD-2-Clause-Patent
+//
+
+package server
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+
+	"github.com/daos-stack/daos/src/control/lib/control"
+	"github.com/daos-stack/daos/src/control/logging"
+	"github.com/daos-stack/daos/src/control/server/cgroup"
+	"github.com/daos-stack/daos/src/control/server/engine"
+	"github.com/daos-stack/daos/src/control/server/storage"
+	"github.com/daos-stack/daos/src/control/server/storage/bdev"
+	"github.com/daos-stack/daos/src/control/system"
+)
+
+type (
+	onInstanceExitFn func(ctx context.Context, engineIdx uint32, rank system.Rank, exitErr error, exitPid int) error
+	onAwaitFormatFn  func(ctx context.Context, engineIdx uint32) error
+	onReadyFn        func(ctx context.Context) error
+	onStorageReadyFn func(ctx context.Context) error
+	onHotplugEventFn func(pciAddr string, inserted bool)
+	systemJoinFn     func(ctx context.Context, req *control.SystemJoinReq) (*control.SystemJoinResp, error)
+)
+
+// superblock persists the identity an engine was assigned the first time it
+// joined the system, so restarts don't get allocated a new rank.
+type superblock struct {
+	ValidRank bool
+	Rank      *system.Rank
+}
+
+// engineRunner is the minimal subset of the process supervisor's interface
+// an EngineInstance needs: access to the config it was started with.
+type engineRunner interface {
+	GetConfig() *engine.Config
+}
+
+type staticEngineRunner struct {
+	cfg *engine.Config
+}
+
+func (r *staticEngineRunner) GetConfig() *engine.Config { return r.cfg }
+
+// EngineInstance wraps a single supervised engine process, its lifecycle
+// callbacks, and the bookkeeping (superblock, SPDK RPC socket) needed to
+// route control-plane requests to it.
+type EngineInstance struct {
+	log    logging.Logger
+	index  uint32
+	runner engineRunner
+	sb     *superblock
+
+	pinnedNumaNode uint32
+	spdkSockPath   string
+	pid            int
+
+	joinSystem systemJoinFn
+
+	// cgroupUsage, when the engine is cgroup-isolated, reads back the
+	// current resource accounting for its scope. Set by
+	// registerEngineCgroupCallbacks once the engine's cgroup.Manager is
+	// known; nil if cgroup isolation is disabled.
+	cgroupUsage func() (*cgroup.Usage, error)
+
+	onInstanceExit []onInstanceExitFn
+	onAwaitFormat  []onAwaitFormatFn
+	onReady        []onReadyFn
+	onStorageReady []onStorageReadyFn
+	onHotplugEvent []onHotplugEventFn
+}
+
+// NewEngineInstance returns a new EngineInstance for the given config, not
+// yet started.
+func NewEngineInstance(log logging.Logger, index uint32, cfg *engine.Config) *EngineInstance {
+	return &EngineInstance{
+		log:    log,
+		index:  index,
+		runner: &staticEngineRunner{cfg: cfg},
+		sb:     &superblock{},
+	}
+}
+
+// Index returns the engine's configured index (0-based position within
+// cfg.Engines), stable across restarts even before a rank is assigned.
+func (ei *EngineInstance) Index() uint32 { return ei.index }
+
+// OnInstanceExit registers a callback invoked when the engine process exits.
+func (ei *EngineInstance) OnInstanceExit(fn onInstanceExitFn) {
+	ei.onInstanceExit = append(ei.onInstanceExit, fn)
+}
+
+// OnAwaitFormat registers a callback invoked when the engine is waiting on
+// storage format to proceed.
+func (ei *EngineInstance) OnAwaitFormat(fn onAwaitFormatFn) {
+	ei.onAwaitFormat = append(ei.onAwaitFormat, fn)
+}
+
+// OnReady registers a callback invoked once the engine reports ready.
+func (ei *EngineInstance) OnReady(fn onReadyFn) {
+	ei.onReady = append(ei.onReady, fn)
+}
+
+// OnStorageReady registers a callback invoked once the engine's SCM is
+// ready, ahead of format/ready.
+func (ei *EngineInstance) OnStorageReady(fn onStorageReadyFn) {
+	ei.onStorageReady = append(ei.onStorageReady, fn)
+}
+
+// OnHotplugEvent registers a callback invoked whenever the engine's SPDK
+// hotplug poller reports an NVMe controller insert or remove.
+func (ei *EngineInstance) OnHotplugEvent(fn onHotplugEventFn) {
+	ei.onHotplugEvent = append(ei.onHotplugEvent, fn)
+}
+
+// getSuperblock returns the engine's persisted identity.
+func (ei *EngineInstance) getSuperblock() *superblock { return ei.sb }
+
+// getPID returns the engine process's current PID, used to place it into a
+// cgroup scope once started.
+func (ei *EngineInstance) getPID() int { return ei.pid }
+
+// CgroupUsage returns the engine's current cgroup resource accounting
+// (CPU/memory/IO), for the Prometheus exporter to surface as
+// engine_cpu_usage_seconds/engine_memory_bytes/engine_io_bytes. Returns an
+// error if cgroup isolation is disabled for this engine.
+func (ei *EngineInstance) CgroupUsage() (*cgroup.Usage, error) {
+	if ei.cgroupUsage == nil {
+		return nil, errors.Errorf("engine %d: cgroup isolation not enabled", ei.index)
+	}
+
+	return ei.cgroupUsage()
+}
+
+// GetPinnedNumaNode returns the NUMA node the engine's resources (CPU,
+// bdevs) are pinned to, used as the cpuset default when no explicit
+// engine.Config.Cgroup.CPUSet is configured. Populated by
+// SetPinnedNumaNode once NUMA topology is known.
+func (ei *EngineInstance) GetPinnedNumaNode() (uint32, error) {
+	return ei.pinnedNumaNode, nil
+}
+
+// SetPinnedNumaNode records the NUMA node this engine's resources are
+// pinned to, once NUMA topology has been detected (see
+// registerEngineCallbacks).
+func (ei *EngineInstance) SetPinnedNumaNode(node uint32) {
+	ei.pinnedNumaNode = node
+}
+
+// bdevTier returns the bdev config for tierIdx, used to name and address
+// devices when attaching/detaching controllers at runtime. The returned
+// pointer aliases the engine's live config, so callers that mutate its
+// DeviceList (e.g. BdevAttach) update the engine's actual bookkeeping.
+func (ei *EngineInstance) bdevTier(tierIdx int) (*storage.BdevConfig, error) {
+	tiers := ei.runner.GetConfig().Storage.Bdev.Tier
+	if tierIdx < 0 || tierIdx >= len(tiers) {
+		return nil, errors.Errorf("engine %d: no bdev tier %d configured (%d tier(s) configured)",
+			ei.index, tierIdx, len(tiers))
+	}
+
+	return &tiers[tierIdx], nil
+}
+
+// bdevPCIAddr resolves an SPDK bdev name (e.g. "Nvme_host_0_0") back to the
+// PCI address it was attached from, searching every configured bdev tier
+// since a detach request only carries the name, not a tier index.
+func (ei *EngineInstance) bdevPCIAddr(name string) (string, error) {
+	for _, tier := range ei.runner.GetConfig().Storage.Bdev.Tier {
+		if addr, err := bdev.PCIAddrForName(&tier, name); err == nil {
+			return addr, nil
+		}
+	}
+
+	return "", errors.Errorf("engine %d: no device named %q in any configured bdev tier", ei.index, name)
+}
+
+// spdkRPCSocket returns the path to the engine's SPDK JSON-RPC unix domain
+// socket, used by BdevAttach/BdevDetach to reach a running engine.
+func (ei *EngineInstance) spdkRPCSocket() string {
+	if ei.spdkSockPath != "" {
+		return ei.spdkSockPath
+	}
+
+	return fmt.Sprintf("/var/run/daos_engine/%d/spdk.sock", ei.index)
+}
+
+// EngineHarness owns the set of engine instances configured on this host
+// and routes rank-addressed requests to the right one.
+type EngineHarness struct {
+	instances []*EngineInstance
+}
+
+// Instances returns every engine instance managed by this harness.
+func (h *EngineHarness) Instances() []*EngineInstance {
+	return h.instances
+}
+
+// GetEngineInstance returns the instance at index idx (engines are
+// addressed by their configured index here rather than DAOS rank, since a
+// rank may not be assigned yet when e.g. hotplug RPCs arrive early).
+func (h *EngineHarness) GetEngineInstance(idx int) (*EngineInstance, error) {
+	for _, ei := range h.instances {
+		if int(ei.Index()) == idx {
+			return ei, nil
+		}
+	}
+
+	return nil, errors.Errorf("no engine instance with index %d", idx)
+}
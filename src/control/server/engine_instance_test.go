@@ -0,0 +1,109 @@
+//
+// (C) Copyright 2021 Intel Corporation.
+//
+// SPDX-License-Identifier: BSD-2-Clause-Patent
+//
+
+package server
+
+import (
+	"testing"
+
+	"github.com/daos-stack/daos/src/control/server/engine"
+	"github.com/daos-stack/daos/src/control/server/storage"
+)
+
+func TestEngineInstance_bdevTier(t *testing.T) {
+	cfg := &engine.Config{
+		Storage: engine.StorageConfig{
+			Bdev: storage.BdevTier{
+				Tier: []storage.BdevConfig{
+					{TierIdx: 0, Class: storage.BdevClassNvme},
+					{TierIdx: 1, Class: storage.BdevClassNvme},
+				},
+			},
+		},
+	}
+	ei := &EngineInstance{runner: &staticEngineRunner{cfg: cfg}}
+
+	for name, tc := range map[string]struct {
+		tierIdx int
+		wantIdx int
+		wantErr bool
+	}{
+		"tier 0":         {tierIdx: 0, wantIdx: 0},
+		"tier 1":         {tierIdx: 1, wantIdx: 1},
+		"out of range":   {tierIdx: 2, wantErr: true},
+		"negative index": {tierIdx: -1, wantErr: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			tier, err := ei.bdevTier(tc.tierIdx)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if tier.TierIdx != tc.wantIdx {
+				t.Fatalf("expected tier %d, got %d", tc.wantIdx, tier.TierIdx)
+			}
+		})
+	}
+}
+
+func TestEngineInstance_bdevPCIAddr(t *testing.T) {
+	cfg := &engine.Config{
+		Storage: engine.StorageConfig{
+			Bdev: storage.BdevTier{
+				Tier: []storage.BdevConfig{
+					{
+						TierIdx:    0,
+						Class:      storage.BdevClassNvme,
+						DeviceList: []string{"0000:81:00.0", "0000:82:00.0"},
+					},
+				},
+			},
+		},
+	}
+	ei := &EngineInstance{index: 3, runner: &staticEngineRunner{cfg: cfg}}
+
+	addr, err := ei.bdevPCIAddr("Nvme_" + cfg.Storage.Bdev.Tier[0].Hostname + "_1_0")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if addr != "0000:82:00.0" {
+		t.Fatalf("expected 0000:82:00.0, got %s", addr)
+	}
+
+	if _, err := ei.bdevPCIAddr("Nvme_unknown_0_0"); err == nil {
+		t.Fatal("expected error for unmatched bdev name, got nil")
+	}
+}
+
+func TestEngineInstance_bdevTier_mutationPersists(t *testing.T) {
+	cfg := &engine.Config{
+		Storage: engine.StorageConfig{
+			Bdev: storage.BdevTier{
+				Tier: []storage.BdevConfig{{TierIdx: 0}},
+			},
+		},
+	}
+	ei := &EngineInstance{runner: &staticEngineRunner{cfg: cfg}}
+
+	tier, err := ei.bdevTier(0)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	tier.DeviceList = append(tier.DeviceList, "0000:81:00.0")
+
+	tier2, err := ei.bdevTier(0)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(tier2.DeviceList) != 1 || tier2.DeviceList[0] != "0000:81:00.0" {
+		t.Fatalf("expected mutation to persist in engine config, got %v", tier2.DeviceList)
+	}
+}
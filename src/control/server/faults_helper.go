@@ -0,0 +1,32 @@
+//
+// (C) Copyright 2021 Intel Corporation.
+//
+// SPDX-License-Identifier: BSD-2-Clause-Patent
+//
+
+package server
+
+// helperFault is a bare, descriptive fault value with no wrapped free-text
+// cause, matching the FaultVfioDisabled/FaultIommuDisabled idiom used
+// elsewhere in this package; the underlying low-level error is logged by the
+// caller rather than folded into the returned error's text.
+type helperFault struct {
+	msg string
+}
+
+func (f *helperFault) Error() string { return f.msg }
+
+var (
+	// FaultHelperCapsDropFailed indicates that the privileged helper launch
+	// path was unable to drop down to the minimum Linux capability set
+	// before running NVMe prep/scan work, analogous to FaultVfioDisabled.
+	FaultHelperCapsDropFailed error = &helperFault{"unable to drop privileged helper capabilities"}
+
+	// FaultHelperSeccompFailed indicates that the bundled seccomp-bpf
+	// allowlist could not be loaded for the privileged helper.
+	FaultHelperSeccompFailed error = &helperFault{"unable to load privileged helper seccomp profile"}
+
+	// FaultHelperApparmorFailed indicates that the configured AppArmor
+	// profile could not be applied to the privileged helper.
+	FaultHelperApparmorFailed error = &helperFault{"unable to apply privileged helper AppArmor profile"}
+)
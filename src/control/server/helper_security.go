@@ -0,0 +1,242 @@
+//
+// (C) Copyright 2021 Intel Corporation.
+//
+// SPDX-License-Identifier: BSD-2-Clause-Patent
+//
+
+package server
+
+import (
+	"fmt"
+	"os"
+
+	"kernel.org/pub/linux/libs/security/libcap/cap"
+
+	"github.com/pkg/errors"
+	"github.com/seccomp/libseccomp-golang"
+
+	"github.com/daos-stack/daos/src/control/logging"
+	"github.com/daos-stack/daos/src/control/server/config"
+)
+
+const apparmorSecurityfsPath = "/sys/kernel/security/apparmor"
+
+// minHelperCaps are the Linux capabilities retained after dropping everything
+// else via PR_CAPBSET_DROP+capset, before a short-lived daos_admin/
+// daos_firmware helper process performs privileged NVMe prep/scan work.
+//
+//	CAP_SYS_ADMIN        VFIO bind/unbind, hugepage reservation
+//	CAP_SYS_RAWIO        PCI config space access
+//	CAP_IPC_LOCK         mlock of DMA buffers
+//	CAP_NET_BIND_SERVICE only needed if the control plane binds <1024
+//
+// The drop is irreversible for the rest of the calling process's life, so it
+// must only ever run in a helper process dedicated to this one task - never
+// in the long-lived daos_server itself, which still needs far more than
+// these four caps (e.g. CAP_DAC_OVERRIDE/CAP_CHOWN/CAP_FOWNER for SCM
+// format) for the rest of its lifetime. CAP_NET_BIND_SERVICE is listed here
+// anyway even though the helper has no use for it, since it costs nothing to
+// retain and keeps this list a superset-safe default if it's ever reused for
+// a helper that also needs it.
+var minHelperCaps = []cap.Value{
+	cap.SYS_ADMIN,
+	cap.SYS_RAWIO,
+	cap.IPC_LOCK,
+	cap.NET_BIND_SERVICE,
+}
+
+// seccompHelperBlocklist are syscalls with no legitimate use in the
+// daos_admin/daos_firmware helpers, blocked via a seccomp-bpf filter
+// (SCMP_ACT_ALLOW default, explicit SCMP_ACT_ERRNO for these).
+var seccompHelperBlocklist = []string{
+	"keyctl",
+	"ptrace",
+	"kexec_load",
+	"kexec_file_load",
+	"mount",
+	"umount2",
+	"pivot_root",
+	"init_module",
+	"finit_module",
+	"delete_module",
+}
+
+// dropHelperCaps drops every Linux capability from the running process
+// except caps. The bounding-set drop alone only stops capabilities from
+// being re-acquired later (e.g. across an exec of a file with capabilities
+// set); it does nothing to capabilities the process already holds, so the
+// effective and permitted sets are dropped here too, confining the calling
+// process itself immediately rather than merely preventing it from regaining
+// privilege later. This is irreversible for the life of the process, so
+// callers must only invoke it from a short-lived process dedicated to the
+// privileged work, never from a process that has other work left to do.
+func dropHelperCaps(caps []cap.Value) error {
+	keep := make(map[cap.Value]struct{}, len(caps))
+	for _, c := range caps {
+		keep[c] = struct{}{}
+	}
+
+	for c := cap.Value(0); c <= cap.MAXBITS; c++ {
+		if _, ok := keep[c]; ok {
+			continue
+		}
+		if supported, _ := c.GetBound(); !supported {
+			continue
+		}
+		if err := c.Unset(cap.BOUNDING); err != nil {
+			return errors.Wrapf(err, "drop capability %s from bounding set", c)
+		}
+	}
+
+	iab := cap.IABGetProc()
+	if err := iab.Fill(cap.Inh); err != nil {
+		return errors.Wrap(err, "clear inheritable capabilities")
+	}
+	for _, c := range caps {
+		if err := iab.SetVector(cap.Inh, true, c); err != nil {
+			return errors.Wrapf(err, "set inheritable capability %s", c)
+		}
+	}
+	if err := iab.SetProc(); err != nil {
+		return errors.Wrap(err, "apply capability set to process")
+	}
+
+	pc := cap.GetProc()
+	for c := cap.Value(0); c <= cap.MAXBITS; c++ {
+		if _, ok := keep[c]; ok {
+			continue
+		}
+		if supported, _ := c.GetBound(); !supported {
+			continue
+		}
+		if err := pc.SetFlag(cap.Effective, false, c); err != nil {
+			return errors.Wrapf(err, "drop capability %s from effective set", c)
+		}
+		if err := pc.SetFlag(cap.Permitted, false, c); err != nil {
+			return errors.Wrapf(err, "drop capability %s from permitted set", c)
+		}
+	}
+	for _, c := range caps {
+		if err := pc.SetFlag(cap.Effective, true, c); err != nil {
+			return errors.Wrapf(err, "retain capability %s in effective set", c)
+		}
+		if err := pc.SetFlag(cap.Permitted, true, c); err != nil {
+			return errors.Wrapf(err, "retain capability %s in permitted set", c)
+		}
+	}
+	if err := pc.SetProc(); err != nil {
+		return errors.Wrap(err, "apply effective/permitted capability set to process")
+	}
+
+	return nil
+}
+
+// loadHelperSeccompProfile installs the bundled seccomp-bpf filter that
+// allows every syscall except those in seccompHelperBlocklist, for the NVMe
+// prep/scan helpers.
+func loadHelperSeccompProfile() error {
+	filter, err := seccomp.NewFilter(seccomp.ActAllow)
+	if err != nil {
+		return errors.Wrap(err, "create seccomp filter")
+	}
+	defer filter.Release()
+
+	for _, name := range seccompHelperBlocklist {
+		syscallID, err := seccomp.GetSyscallFromName(name)
+		if err != nil {
+			// Not every syscall in the blocklist exists on every
+			// arch/kernel combination; skip rather than fail.
+			continue
+		}
+		if err := filter.AddRule(syscallID, seccomp.ActErrno.SetReturnCode(1)); err != nil {
+			return errors.Wrapf(err, "add seccomp rule for %s", name)
+		}
+	}
+
+	if err := filter.Load(); err != nil {
+		return errors.Wrap(err, "load seccomp filter")
+	}
+
+	return nil
+}
+
+// applyHelperApparmorProfile confines the process to the named AppArmor
+// profile, if AppArmor is enabled on the host (securityfs mounted at
+// apparmorSecurityfsPath). A missing AppArmor stack is not an error; the
+// profile name is simply ignored so non-AppArmor hosts aren't penalized.
+func applyHelperApparmorProfile(name string) error {
+	if name == "" {
+		return nil
+	}
+	if !apparmorAvailable() {
+		return nil
+	}
+
+	if err := changeAppArmorHat(name); err != nil {
+		return errors.Wrapf(err, "apply AppArmor profile %q", name)
+	}
+
+	return nil
+}
+
+// apparmorAvailable reports whether the AppArmor LSM is loaded, identified
+// by the securityfs mount at apparmorSecurityfsPath.
+func apparmorAvailable() bool {
+	_, err := os.Stat(apparmorSecurityfsPath)
+	return err == nil
+}
+
+// changeAppArmorHat confines the calling process to profile name immediately,
+// by writing the "changeprofile" directive to /proc/self/attr/current, the
+// standard textual LSM protocol for an in-place profile transition that
+// takes effect on the write itself rather than on a subsequent exec(). This
+// is the correct mode for secureHelperLaunch's caller, which confines a
+// short-lived helper process to NVMe prep/scan work it is about to perform
+// itself, with no following exec.
+func changeAppArmorHat(name string) error {
+	f, err := os.OpenFile("/proc/self/attr/current", os.O_WRONLY, 0)
+	if err != nil {
+		return errors.Wrap(err, "open /proc/self/attr/current")
+	}
+	defer f.Close()
+
+	if _, err := fmt.Fprintf(f, "changeprofile %s", name); err != nil {
+		return errors.Wrap(err, "write AppArmor changeprofile directive")
+	}
+
+	return nil
+}
+
+// secureHelperLaunch drops capabilities down to the fixed minHelperCaps set
+// (cfg has no setting that changes which capabilities are retained) and
+// applies the seccomp/AppArmor profiles to the calling process itself,
+// confining it before it goes on to do privileged NVMe prep/scan work. The
+// drop is irreversible for the rest of the process's life, so this must only
+// ever be called from within a short-lived daos_admin/daos_firmware helper
+// process dedicated to that work - never from the long-lived daos_server
+// itself, which needs capabilities and syscalls (e.g. mount, for SCM format)
+// well beyond this set for the rest of its lifetime. There is no fork/exec
+// call site for that helper process in this tree yet, so this function is
+// currently unreachable; daos_admin/daos_firmware are expected to call it (or
+// the equivalent) at their own process startup, using the AppArmor profile
+// name setDaosHelperEnvs has already exported into their environment.
+func secureHelperLaunch(log logging.Logger, cfg *config.Server) error {
+	if err := dropHelperCaps(minHelperCaps); err != nil {
+		log.Errorf("drop privileged helper capabilities: %s", err)
+		return FaultHelperCapsDropFailed
+	}
+
+	if err := loadHelperSeccompProfile(); err != nil {
+		log.Errorf("load privileged helper seccomp profile: %s", err)
+		return FaultHelperSeccompFailed
+	}
+
+	if err := applyHelperApparmorProfile(cfg.HelperApparmorProfile); err != nil {
+		log.Errorf("apply privileged helper AppArmor profile: %s", err)
+		return FaultHelperApparmorFailed
+	}
+
+	log.Debugf("privileged helper launch secured: caps=%v apparmor=%q", minHelperCaps, cfg.HelperApparmorProfile)
+
+	return nil
+}
@@ -0,0 +1,34 @@
+//
+// (C) Copyright 2021 Intel Corporation.
+//
+// SPDX-License-Identifier: BSD-2-Clause-Patent
+//
+
+package server
+
+import "testing"
+
+func TestApplyHelperApparmorProfile_NoProfile(t *testing.T) {
+	if err := applyHelperApparmorProfile(""); err != nil {
+		t.Fatalf("expected no error for empty profile name, got %s", err)
+	}
+}
+
+func TestFaultHelperValues(t *testing.T) {
+	for name, fault := range map[string]error{
+		"caps":     FaultHelperCapsDropFailed,
+		"seccomp":  FaultHelperSeccompFailed,
+		"apparmor": FaultHelperApparmorFailed,
+	} {
+		if fault == nil {
+			t.Fatalf("%s: expected non-nil fault value", name)
+		}
+		if fault.Error() == "" {
+			t.Fatalf("%s: expected non-empty fault message", name)
+		}
+	}
+
+	if FaultHelperCapsDropFailed == FaultHelperSeccompFailed {
+		t.Fatal("expected distinct fault values")
+	}
+}
@@ -0,0 +1,128 @@
+//
+// (C) Copyright 2021 Intel Corporation.
+//
+// SPDX-License-Identifier: BSD-2-Clause-Patent
+//
+
+package server
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/daos-stack/daos/src/control/common/proto/ctl"
+	"github.com/daos-stack/daos/src/control/events"
+	"github.com/daos-stack/daos/src/control/logging"
+	"github.com/daos-stack/daos/src/control/server/storage/bdev"
+)
+
+// mgmtSvc implements the control-plane-facing management gRPC service,
+// routing bdev attach/detach requests to the right engine instance via
+// harness.
+type mgmtSvc struct {
+	log     logging.Logger
+	harness *EngineHarness
+	events  *events.PubSub
+}
+
+// reqGroupUpdate asks the MS to push a fresh group map to every engine;
+// invoked after membership changes (e.g. a rank is marked dead).
+func (svc *mgmtSvc) reqGroupUpdate(ctx context.Context) {
+	svc.log.Debug("mgmt: group update requested")
+}
+
+// publishBdevHotplugFn returns an onHotplugEventFn closure that publishes a
+// RASTypeStateChange event describing a hotplug insert/remove so that, once
+// forwarded to the MS leader, smd devices can be marked faulty/new in
+// response. Mirrors the shape of publishInstanceExitFn et al.
+func publishBdevHotplugFn(publish func(*events.RASEvent), hostname string) onHotplugEventFn {
+	return func(pciAddr string, inserted bool) {
+		evt := events.NewBdevHotplugEvent(hostname, pciAddr, inserted)
+		publish(evt)
+	}
+}
+
+// pciAddrAllowed reports whether addr may be attached given a tier's hotplug
+// allow list; an empty list permits any address.
+func pciAddrAllowed(allow []string, addr string) bool {
+	if len(allow) == 0 {
+		return true
+	}
+
+	for _, a := range allow {
+		if a == addr {
+			return true
+		}
+	}
+
+	return false
+}
+
+// BdevAttach handles a request to attach a live NVMe controller to a running
+// engine via SPDK JSON-RPC, for a device that was not part of the config
+// generated at engine start (a hotplug insertion or an operator-initiated
+// attach of a pre-existing but unbound device).
+func (svc *mgmtSvc) BdevAttach(ctx context.Context, req *ctl.BdevAttachReq) (*ctl.BdevAttachResp, error) {
+	if req == nil {
+		return nil, errors.New("nil BdevAttachReq")
+	}
+
+	ei, err := svc.harness.GetEngineInstance(int(req.EngineIdx))
+	if err != nil {
+		return nil, err
+	}
+
+	tier, err := ei.bdevTier(int(req.TierIdx))
+	if err != nil {
+		return nil, err
+	}
+
+	if !pciAddrAllowed(tier.Hotplug.AllowList, req.PciAddr) {
+		return nil, errors.Errorf("attach %s on engine %d tier %d: not in hotplug allow list",
+			req.PciAddr, req.EngineIdx, req.TierIdx)
+	}
+
+	if err := bdev.AttachController(svc.log, ei.spdkRPCSocket(), tier, req.PciAddr); err != nil {
+		return nil, errors.Wrapf(err, "attach %s on engine %d tier %d", req.PciAddr, req.EngineIdx, req.TierIdx)
+	}
+
+	// Reflect the newly attached device in the tier's own bookkeeping, so
+	// a subsequent attach on this tier picks a fresh index/name instead
+	// of recomputing the one just used.
+	tier.DeviceList = append(tier.DeviceList, req.PciAddr)
+
+	svc.events.Publish(events.NewBdevHotplugEvent(hostname(), req.PciAddr, true))
+
+	return &ctl.BdevAttachResp{}, nil
+}
+
+// BdevDetach handles a request to detach a live NVMe controller from a
+// running engine via SPDK JSON-RPC.
+func (svc *mgmtSvc) BdevDetach(ctx context.Context, req *ctl.BdevDetachReq) (*ctl.BdevDetachResp, error) {
+	if req == nil {
+		return nil, errors.New("nil BdevDetachReq")
+	}
+
+	ei, err := svc.harness.GetEngineInstance(int(req.EngineIdx))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := bdev.DetachController(svc.log, ei.spdkRPCSocket(), req.Name); err != nil {
+		return nil, errors.Wrapf(err, "detach %s on engine %d", req.Name, req.EngineIdx)
+	}
+
+	// The event's PCIAddr field is meant to carry a PCI address, not an SPDK
+	// bdev name; resolve the one req.Name was attached from so consumers
+	// that key off it (e.g. marking an smd device faulty) get a real BDF.
+	pciAddr, err := ei.bdevPCIAddr(req.Name)
+	if err != nil {
+		svc.log.Errorf("detach %s on engine %d: resolve PCI address: %s", req.Name, req.EngineIdx, err)
+		pciAddr = req.Name
+	}
+
+	svc.events.Publish(events.NewBdevHotplugEvent(hostname(), pciAddr, false))
+
+	return &ctl.BdevDetachResp{}, nil
+}
@@ -0,0 +1,38 @@
+//
+// (C) Copyright 2021 Intel Corporation.
+//
+// SPDX-License-Identifier: BSD-2-Clause-Patent
+//
+
+package server
+
+import "testing"
+
+func TestPciAddrAllowed(t *testing.T) {
+	for name, tc := range map[string]struct {
+		allow []string
+		addr  string
+		want  bool
+	}{
+		"empty allow list permits any address": {
+			addr: "0000:81:00.0",
+			want: true,
+		},
+		"address in allow list": {
+			allow: []string{"0000:81:00.0", "0000:82:00.0"},
+			addr:  "0000:82:00.0",
+			want:  true,
+		},
+		"address not in allow list": {
+			allow: []string{"0000:81:00.0"},
+			addr:  "0000:82:00.0",
+			want:  false,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if got := pciAddrAllowed(tc.allow, tc.addr); got != tc.want {
+				t.Fatalf("expected %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
@@ -14,10 +14,12 @@ import (
 	"os"
 	"os/user"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 
 	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
 	"google.golang.org/grpc"
 
 	"github.com/daos-stack/daos/src/control/events"
@@ -26,6 +28,7 @@ import (
 	"github.com/daos-stack/daos/src/control/logging"
 	"github.com/daos-stack/daos/src/control/pbin"
 	"github.com/daos-stack/daos/src/control/security"
+	"github.com/daos-stack/daos/src/control/server/cgroup"
 	"github.com/daos-stack/daos/src/control/server/config"
 	"github.com/daos-stack/daos/src/control/server/engine"
 	"github.com/daos-stack/daos/src/control/server/storage/bdev"
@@ -41,12 +44,29 @@ type resolveTCPFn func(string, string) (*net.TCPAddr, error)
 const (
 	iommuPath        = "/sys/class/iommu"
 	minHugePageCount = 128
+	nodeCPUListFmt   = "/sys/devices/system/node/node%d/cpulist"
 )
 
+// numaNodeCPUList returns the cpulist (e.g. "0-15") of the CPUs local to
+// node, read from sysfs, for use as a cpuset.cpus value. A bare NUMA node
+// number is not a valid cpuset.cpus entry.
+func numaNodeCPUList(node uint32) (string, error) {
+	path := fmt.Sprintf(nodeCPUListFmt, node)
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", errors.Wrapf(err, "read %s", path)
+	}
+
+	return strings.TrimSpace(string(b)), nil
+}
+
 func cfgHasBdevs(cfg *config.Server) bool {
 	for _, engineCfg := range cfg.Engines {
-		if len(engineCfg.Storage.Bdev.DeviceList) > 0 {
-			return true
+		for _, tier := range engineCfg.Storage.Bdev.Tier {
+			if len(tier.DeviceList) > 0 {
+				return true
+			}
 		}
 	}
 
@@ -165,19 +185,90 @@ func netInit(ctx context.Context, log *logging.LeveledLogger, cfg *config.Server
 	return netDevClass, nil
 }
 
+// prepSRIOVStorage brings every configured PF's VF count in line with its
+// BdevSRIOV stanza and returns the resulting VF BDFs keyed by the owning
+// engine's configured index (SRIOVConfig.EngineIdx), so the caller can fold
+// them into both the VFIO allowlist and that engine's bdev DeviceList -
+// without the latter, SPDK would never attach the dynamically-discovered
+// VFs since its bdev config is generated solely from DeviceList.
+// Reconfiguration failures are published via the event pubsub rather than
+// aborting the whole prepare, so cluster-wide orchestration can react and
+// unaffected PFs still get their VFs bound.
+func prepSRIOVStorage(log logging.Logger, publish func(*events.RASEvent), sriovCfgs []bdev.SRIOVConfig) map[int][]string {
+	vfAddrsByEngine := make(map[int][]string)
+
+	for i := range sriovCfgs {
+		cfg := &sriovCfgs[i]
+
+		if err := bdev.ConfigureVFs(log, cfg); err != nil {
+			log.Errorf("sriov: failed to configure %d VFs on %s: %s", cfg.NumVFs, cfg.PF, err)
+			publish(events.NewSRIOVReconfigureFailedEvent(hostname(), cfg.PF, err))
+			continue
+		}
+
+		addrs, err := bdev.VFAddresses(cfg.PF)
+		if err != nil {
+			log.Errorf("sriov: failed to enumerate VFs on %s: %s", cfg.PF, err)
+			publish(events.NewSRIOVReconfigureFailedEvent(hostname(), cfg.PF, err))
+			continue
+		}
+
+		vfAddrsByEngine[cfg.EngineIdx] = append(vfAddrsByEngine[cfg.EngineIdx], addrs...)
+	}
+
+	return vfAddrsByEngine
+}
+
 func prepBdevStorage(srv *server, usr *user.User, iommuEnabled bool, hpiGetter getHugePageInfoFn) error {
+	// Configure any requested SR-IOV virtual functions before assembling
+	// the prepare request, so their BDFs can be folded into the VFIO
+	// allowlist. VF BDFs are accepted as first-class DeviceList entries
+	// downstream (bdev_nvme_attach_controller doesn't distinguish a VF
+	// from a PF), so no further special-casing is needed once bound.
+	vfAddrsByEngine := prepSRIOVStorage(srv.log, srv.pubSub.Publish, srv.cfg.BdevSRIOV)
+
+	// Append each engine's discovered VFs to its own bdev DeviceList - the
+	// SPDK bdev config is generated solely from DeviceList, so without this
+	// the VFs would be bound to vfio-pci but never actually attached.
+	var allVFAddrs []string
+	for idx, addrs := range vfAddrsByEngine {
+		if idx < 0 || idx >= len(srv.cfg.Engines) {
+			srv.log.Errorf("sriov: VF(s) %v configured for out-of-range engine index %d", addrs, idx)
+			continue
+		}
+
+		engineCfg := srv.cfg.Engines[idx]
+		if len(engineCfg.Storage.Bdev.Tier) == 0 {
+			srv.log.Errorf("sriov: VF(s) %v configured for engine %d with no bdev tiers configured", addrs, idx)
+			continue
+		}
+
+		// SRIOVConfig doesn't carry a tier index, so discovered VFs
+		// always land on the engine's first bdev tier.
+		engineCfg.Storage.Bdev.Tier[0].DeviceList = append(engineCfg.Storage.Bdev.Tier[0].DeviceList, addrs...)
+		allVFAddrs = append(allVFAddrs, addrs...)
+	}
+
 	// Perform an automatic prepare based on the values in the config file.
 	prepReq := bdev.PrepareRequest{
 		// Default to minimum necessary for scan to work correctly.
 		HugePageCount: minHugePageCount,
 		TargetUser:    usr.Username,
-		PCIAllowlist:  strings.Join(srv.cfg.BdevInclude, " "),
+		PCIAllowlist:  strings.Join(append(append([]string{}, srv.cfg.BdevInclude...), allVFAddrs...), " "),
 		PCIBlocklist:  strings.Join(srv.cfg.BdevExclude, " "),
 		DisableVFIO:   srv.cfg.DisableVFIO,
 		DisableVMD:    srv.cfg.DisableVMD || srv.cfg.DisableVFIO || !iommuEnabled,
 		// TODO: pass vmd include list
 	}
 
+	// Export the AppArmor profile (and helper log file paths) daos_admin/
+	// daos_firmware need to confine and log themselves at their own process
+	// startup, whether or not bdevProvider.Prepare below ends up delegating
+	// to one of them for this particular user.
+	if err := setDaosHelperEnvs(srv.cfg, os.Setenv); err != nil {
+		return err
+	}
+
 	hasBdevs := cfgHasBdevs(srv.cfg)
 	if hasBdevs {
 		// The config value is intended to be per-engine, so we need to adjust
@@ -197,12 +288,37 @@ func prepBdevStorage(srv *server, usr *user.User, iommuEnabled bool, hpiGetter g
 		}
 	}
 
+	// When any engine has hotplug enabled, devices listed in its config
+	// are allowed to be absent at boot (e.g. a VF that will be attached
+	// later); a prepare error is then logged at debug level instead of as
+	// an error. A prepare error never fails this function either way.
+	hotplugCfgd := false
+	for _, engineCfg := range srv.cfg.Engines {
+		for _, tier := range engineCfg.Storage.Bdev.Tier {
+			if tier.Hotplug.Enable {
+				hotplugCfgd = true
+				break
+			}
+		}
+		if hotplugCfgd {
+			break
+		}
+	}
+
+	// secureHelperLaunch is intentionally not called here: it drops
+	// capabilities/installs seccomp irreversibly for the rest of the
+	// process's life, which is only safe inside a short-lived process
+	// dedicated to NVMe prep. Applying it to this long-lived daos_server
+	// process would strip capabilities (e.g. CAP_DAC_OVERRIDE/CAP_CHOWN/
+	// CAP_FOWNER) and block syscalls (e.g. mount/umount2) that SCM
+	// format/start still need later in the same process. It's wired up
+	// only once there's an actual forked daos_admin/daos_firmware child
+	// to scope it to.
+
 	// TODO: should be passing root context into prepare request to
 	//       facilitate cancellation.
 	srv.log.Debugf("automatic NVMe prepare req: %+v", prepReq)
-	if _, err := srv.bdevProvider.Prepare(prepReq); err != nil {
-		srv.log.Errorf("automatic NVMe prepare failed (check configuration?)\n%s", err)
-	}
+	_, prepErr := srv.bdevProvider.Prepare(prepReq)
 
 	hugePages, err := hpiGetter()
 	if err != nil {
@@ -216,10 +332,30 @@ func prepBdevStorage(srv *server, usr *user.User, iommuEnabled bool, hpiGetter g
 		}
 	}
 
+	if prepErr != nil {
+		// Hugepages having come up as requested despite the error is a good
+		// proxy for "the bind step just found fewer devices than configured"
+		// rather than a fundamentally broken config (bad driver, disabled
+		// IOMMU, ...); only tolerate the former, and only when hotplug is
+		// configured to actually pick the missing devices up later.
+		if hotplugCfgd && (!hasBdevs || hugePages.Free >= prepReq.HugePageCount) {
+			srv.log.Debugf("automatic NVMe prepare found fewer devices than configured "+
+				"(tolerated, hotplug enabled): %s", prepErr)
+		} else {
+			srv.log.Errorf("automatic NVMe prepare failed (check configuration?)\n%s", prepErr)
+		}
+	}
+
 	return nil
 }
 
 func setDaosHelperEnvs(cfg *config.Server, setenv func(k, v string) error) error {
+	if cfg.HelperApparmorProfile != "" {
+		if err := setenv(pbin.DaosAdminApparmorProfileEnvVar, cfg.HelperApparmorProfile); err != nil {
+			return errors.Wrap(err, "unable to configure privileged helper AppArmor profile")
+		}
+	}
+
 	if cfg.HelperLogFile != "" {
 		if err := setenv(pbin.DaosAdminLogFileEnvVar, cfg.HelperLogFile); err != nil {
 			return errors.Wrap(err, "unable to configure privileged helper logging")
@@ -235,13 +371,35 @@ func setDaosHelperEnvs(cfg *config.Server, setenv func(k, v string) error) error
 	return nil
 }
 
-func registerEngineCallbacks(engine *EngineInstance, pubSub *events.PubSub, allStarted *sync.WaitGroup) {
+// registerEngineCallbacks wires up engine lifecycle callbacks. numaCount is
+// the host's NUMA node count (netdetect.NumNumaNodes, as checked by
+// netInit); engines are pinned round-robin across detected nodes (index
+// modulo numaCount) so registerEngineCgroupCallbacks has a real node to
+// derive cpuset limits from. A numaCount of 0 (no NUMA awareness, or
+// netdetect unavailable) pins every engine to node 0. netInit itself returns
+// the fabric device class, not this count, so callers must obtain it
+// separately; passing a zero value silently pins every engine to node 0
+// regardless of topology.
+func registerEngineCallbacks(engine *EngineInstance, pubSub *events.PubSub, allStarted *sync.WaitGroup, numaCount int) {
+	if numaCount > 0 {
+		engine.SetPinnedNumaNode(uint32(int(engine.Index()) % numaCount))
+	}
+
 	// Register callback to publish engine process exit events.
 	engine.OnInstanceExit(publishInstanceExitFn(pubSub.Publish, hostname()))
 
 	// Register callback to publish engine format requested events.
 	engine.OnAwaitFormat(publishFormatRequiredFn(pubSub.Publish, hostname()))
 
+	// Register callback to publish NVMe hotplug insert/remove events
+	// raised by the engine's SPDK hotplug poller, so that the MS leader
+	// can react (e.g. mark smd devices faulty/new).
+	engine.OnHotplugEvent(publishBdevHotplugFn(pubSub.Publish, hostname()))
+
+	// Register callbacks to isolate the engine into its own cgroup scope
+	// once its PID is known, and to clean the scope up on exit.
+	registerEngineCgroupCallbacks(engine)
+
 	var onceReady sync.Once
 	engine.OnReady(func(_ context.Context) error {
 		// Indicate that engine has been started, only do this
@@ -255,6 +413,69 @@ func registerEngineCallbacks(engine *EngineInstance, pubSub *events.PubSub, allS
 	})
 }
 
+// registerEngineCgroupCallbacks places the engine's PID into a dedicated
+// cgroup v2 scope (falling back to v1) once it's running, applying limits
+// derived from engine.Config, and removes the scope again on exit. The
+// engine's own index is used to name the scope rather than its DAOS rank,
+// since the rank isn't known until after the engine has joined the system.
+func registerEngineCgroupCallbacks(engine *EngineInstance) {
+	cfg := engine.runner.GetConfig()
+	if !cfg.Cgroup.Enabled {
+		return
+	}
+
+	mgr := cgroup.NewManager(engine.log, cfg.Cgroup.Parent)
+	idx := uint32(engine.Index())
+
+	// Let the Prometheus exporter (registerTelemetryCallbacks) read back
+	// this engine's current CPU/memory/IO accounting.
+	engine.cgroupUsage = func() (*cgroup.Usage, error) {
+		return mgr.Usage(idx)
+	}
+
+	engine.OnReady(func(_ context.Context) error {
+		// cpuset.cpus wants a CPU range (e.g. "0-15"); cpuset.mems wants
+		// NUMA node ids (e.g. "0"). These are never the same string, so
+		// they're derived independently rather than sharing one value.
+		cpuSet := cfg.Cgroup.CPUSet
+		memsAllowed := ""
+
+		if numaNode, err := engine.GetPinnedNumaNode(); err == nil {
+			memsAllowed = strconv.Itoa(int(numaNode))
+
+			if cpuSet == "" {
+				if cpuList, err := numaNodeCPUList(numaNode); err == nil {
+					cpuSet = cpuList
+				} else {
+					engine.log.Errorf("cgroup: failed to resolve cpulist for numa node %d, leaving cpuset.cpus unset: %s", numaNode, err)
+				}
+			}
+		}
+
+		lim := cgroup.Limits{
+			CPUSet:      cpuSet,
+			MemsAllowed: memsAllowed,
+			MemoryMax:   cfg.Cgroup.MemoryMax,
+			IOWeight:    cfg.Cgroup.IOWeight,
+			PidsMax:     cfg.Cgroup.PidsMax,
+		}
+
+		if err := mgr.Apply(idx, engine.getPID(), lim); err != nil {
+			engine.log.Errorf("cgroup: failed to isolate engine %d: %s", idx, err)
+		}
+
+		return nil
+	})
+
+	engine.OnInstanceExit(func(_ context.Context, _ uint32, _ system.Rank, _ error, _ int) error {
+		if err := mgr.Remove(idx); err != nil {
+			engine.log.Errorf("cgroup: failed to remove scope for engine %d: %s", idx, err)
+		}
+
+		return nil
+	})
+}
+
 func configureFirstEngine(ctx context.Context, engine *EngineInstance, sysdb *system.Database, joinFn systemJoinFn) {
 	if !sysdb.IsReplica() {
 		return
@@ -294,8 +515,13 @@ func configureFirstEngine(ctx context.Context, engine *EngineInstance, sysdb *sy
 	}
 }
 
-// registerTelemetryCallbacks sets telemetry related callbacks to
-// be triggered when all engines have been started.
+// registerTelemetryCallbacks sets telemetry related callbacks to be
+// triggered when all engines have been started, and starts the Prometheus
+// exporter. Cgroup-isolated engines (registerEngineCgroupCallbacks) expose
+// their CPU/memory/IO accounting via EngineInstance.CgroupUsage; a
+// cgroupUsageCollector polls that on every instance and exports it as the
+// engine_cpu_usage_seconds/engine_io_bytes counters and the
+// engine_memory_bytes gauge, skipping instances that aren't cgroup-isolated.
 func registerTelemetryCallbacks(ctx context.Context, srv *server) {
 	telemPort := srv.cfg.TelemetryPort
 	if telemPort == 0 {
@@ -303,6 +529,10 @@ func registerTelemetryCallbacks(ctx context.Context, srv *server) {
 	}
 
 	srv.OnEnginesStarted(func(ctxIn context.Context) error {
+		if err := prometheus.Register(newCgroupUsageCollector(srv.harness.Instances)); err != nil {
+			srv.log.Errorf("telemetry: failed to register cgroup usage collector: %s", err)
+		}
+
 		srv.log.Debug("starting Prometheus exporter")
 		cleanup, err := startPrometheusExporter(ctxIn, srv.log, telemPort, srv.harness.Instances())
 		if err != nil {
@@ -0,0 +1,44 @@
+//
+// (C) Copyright 2021 Intel Corporation.
+//
+// SPDX-License-Identifier: BSD-2-Clause-Patent
+//
+
+package server
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/daos-stack/daos/src/control/events"
+	"github.com/daos-stack/daos/src/control/server/engine"
+)
+
+func TestRegisterEngineCallbacks_numaCount(t *testing.T) {
+	for name, tc := range map[string]struct {
+		index     uint32
+		numaCount int
+		wantNode  uint32
+	}{
+		"no numa awareness pins to node 0": {index: 1, numaCount: 0, wantNode: 0},
+		"index within numa count":          {index: 1, numaCount: 4, wantNode: 1},
+		"index wraps round-robin":          {index: 5, numaCount: 4, wantNode: 1},
+	} {
+		t.Run(name, func(t *testing.T) {
+			ei := &EngineInstance{
+				index:  tc.index,
+				runner: &staticEngineRunner{cfg: &engine.Config{}},
+			}
+
+			registerEngineCallbacks(ei, events.NewPubSub(), &sync.WaitGroup{}, tc.numaCount)
+
+			node, err := ei.GetPinnedNumaNode()
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if node != tc.wantNode {
+				t.Fatalf("expected pinned node %d, got %d", tc.wantNode, node)
+			}
+		})
+	}
+}
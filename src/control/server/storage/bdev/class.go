@@ -8,6 +8,7 @@ package bdev
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -30,8 +31,8 @@ const (
     TimeoutUsec 0
     ActionOnTimeout None
     AdminPollRate 100000
-    HotplugEnable No
-    HotplugPollRate 0
+    HotplugEnable {{ if .Hotplug.Enable }}Yes{{ else }}No{{ end }}
+    HotplugPollRate {{ .Hotplug.PollRateUsec }}
 `
 	// device block size hardcoded to 4096
 	fileTempl = `[AIO]
@@ -47,6 +48,23 @@ const (
 	gbyte   = 1000000000
 	blkSize = 4096
 
+	// nvmeRetryCount, nvmeTimeoutUsec, nvmeActionOnTimeout and
+	// nvmeAdminPollRateUsec mirror the values hard-coded into nvmeTempl
+	// above and are reused by the JSON-RPC config generator so that the
+	// two formats stay behaviourally equivalent while BdevTier.ConfigFormat
+	// defaults to "legacy".
+	nvmeRetryCount        = 4
+	nvmeTimeoutUsec       = 0
+	nvmeActionOnTimeout   = "None"
+	nvmeAdminPollRateUsec = 100000
+
+	// configFormatLegacy selects the legacy INI-style daos_nvme.conf
+	// consumed by SPDK via "-c". configFormatJSON selects the SPDK
+	// JSON-RPC config-file schema. BdevTier.ConfigFormat defaults to the
+	// zero value, which is treated as configFormatLegacy.
+	configFormatLegacy = "legacy"
+	configFormatJSON   = "json"
+
 	msgBdevNone    = "in config, no nvme.conf generated for server"
 	msgBdevEmpty   = "bdev device list entry empty"
 	msgBdevBadSize = "backfile_size should be greater than 0"
@@ -55,6 +73,7 @@ const (
 // bdev describes parameters and behaviors for a particular bdev class.
 type bdev struct {
 	templ   string
+	genJSON func(*storage.BdevConfig) []spdkSubsystemConfig
 	vosEnv  string
 	isEmpty func(*storage.BdevConfig) string                // check no elements
 	isValid func(*storage.BdevConfig) string                // check valid elements
@@ -156,6 +175,159 @@ func genFromTempl(cfg *storage.BdevConfig, templ string) (out bytes.Buffer, err
 	return
 }
 
+// spdkSubsystemConfig is a single JSON-RPC method call nested under a
+// subsystem in the SPDK JSON config-file schema, e.g.
+// {"method": "bdev_nvme_attach_controller", "params": {...}}.
+type spdkSubsystemConfig struct {
+	Method string      `json:"method"`
+	Params interface{} `json:"params,omitempty"`
+}
+
+// spdkSubsystem groups an ordered list of JSON-RPC method calls to be
+// replayed against a single SPDK subsystem (e.g. "bdev" or "vmd") on
+// startup.
+type spdkSubsystem struct {
+	Name    string                `json:"subsystem"`
+	Configs []spdkSubsystemConfig `json:"config"`
+}
+
+// spdkConfig is the top-level document for the SPDK JSON-RPC config-file
+// schema consumed via "--json-config".
+type spdkConfig struct {
+	Subsystems []spdkSubsystem `json:"subsystems"`
+}
+
+type vmdEnableParams struct {
+	Enable bool `json:"enable"`
+}
+
+type nvmeSetOptionsParams struct {
+	RetryCount         int    `json:"retry_count"`
+	TimeoutUsec        int    `json:"timeout_us"`
+	ActionOnTimeout    string `json:"action_on_timeout"`
+	NvmeAdminqPollUsec int    `json:"nvme_adminq_poll_period_us"`
+	HotplugEnable      bool   `json:"bdev_nvme_hotplug_enable"`
+	HotplugPollUsec    int    `json:"bdev_nvme_hotplug_poll_period_us"`
+}
+
+type nvmeAttachControllerParams struct {
+	Name             string `json:"name"`
+	TransportType    string `json:"trtype"`
+	TransportAddress string `json:"traddr"`
+}
+
+type aioCreateParams struct {
+	Name      string `json:"name"`
+	Filename  string `json:"filename"`
+	BlockSize int    `json:"block_size,omitempty"`
+}
+
+type mallocCreateParams struct {
+	Name      string `json:"name"`
+	NumBlocks int    `json:"num_blocks"`
+	BlockSize int    `json:"block_size"`
+}
+
+// bdevName mirrors the Nvme_{host}_{idx}_{tier}/AIO_{host}_{idx}_{tier}
+// naming convention baked into the legacy templates above.
+func bdevName(prefix string, cfg *storage.BdevConfig, idx int) string {
+	return fmt.Sprintf("%s_%s_%d_%d", prefix, cfg.Hostname, idx, cfg.TierIdx)
+}
+
+// nvmeSetOptionsConfig builds the bdev_nvme_set_options call applying hp
+// document-wide. Unlike bdev_nvme_attach_controller, this is a single
+// global SPDK option, so it must only be emitted once per config document
+// (see genConfigFileJSON) rather than once per NVMe tier.
+func nvmeSetOptionsConfig(hp storage.BdevHotplugConfig) spdkSubsystemConfig {
+	return spdkSubsystemConfig{
+		Method: "bdev_nvme_set_options",
+		Params: nvmeSetOptionsParams{
+			RetryCount:         nvmeRetryCount,
+			TimeoutUsec:        nvmeTimeoutUsec,
+			ActionOnTimeout:    nvmeActionOnTimeout,
+			NvmeAdminqPollUsec: nvmeAdminPollRateUsec,
+			HotplugEnable:      hp.Enable,
+			HotplugPollUsec:    int(hp.PollRateUsec),
+		},
+	}
+}
+
+// usesNvmeSetOptions reports whether class is backed by genNvmeJSON, and so
+// shares the single document-wide bdev_nvme_set_options call.
+func usesNvmeSetOptions(class storage.BdevClass) bool {
+	return class == storage.BdevClassNone || class == storage.BdevClassNvme
+}
+
+func genNvmeJSON(cfg *storage.BdevConfig) []spdkSubsystemConfig {
+	cfgs := make([]spdkSubsystemConfig, 0, len(cfg.DeviceList))
+
+	for i, addr := range cfg.DeviceList {
+		cfgs = append(cfgs, spdkSubsystemConfig{
+			Method: "bdev_nvme_attach_controller",
+			Params: nvmeAttachControllerParams{
+				Name:             bdevName("Nvme", cfg, i),
+				TransportType:    "PCIe",
+				TransportAddress: addr,
+			},
+		})
+	}
+
+	return cfgs
+}
+
+func genFileJSON(cfg *storage.BdevConfig) []spdkSubsystemConfig {
+	cfgs := make([]spdkSubsystemConfig, 0, len(cfg.DeviceList))
+	for i, path := range cfg.DeviceList {
+		cfgs = append(cfgs, spdkSubsystemConfig{
+			Method: "bdev_aio_create",
+			Params: aioCreateParams{
+				Name:      bdevName("AIO", cfg, i),
+				Filename:  path,
+				BlockSize: blkSize,
+			},
+		})
+	}
+
+	return cfgs
+}
+
+func genKdevJSON(cfg *storage.BdevConfig) []spdkSubsystemConfig {
+	cfgs := make([]spdkSubsystemConfig, 0, len(cfg.DeviceList))
+	for i, path := range cfg.DeviceList {
+		cfgs = append(cfgs, spdkSubsystemConfig{
+			Method: "bdev_aio_create",
+			Params: aioCreateParams{
+				Name:     bdevName("AIO", cfg, i),
+				Filename: path,
+			},
+		})
+	}
+
+	return cfgs
+}
+
+// genMallocJSON mirrors mallocTempl's "NumberOfLuns {{.DeviceCount}}" /
+// "LunSizeInMB {{.FileSize}}000" semantics: DeviceCount malloc bdevs, each
+// sized FileSize*1000 MB (FileSize is in GB, as for the AIO file tiers).
+func genMallocJSON(cfg *storage.BdevConfig) []spdkSubsystemConfig {
+	lunSizeBytes := int64(cfg.FileSize) * 1000 * 1000000 // FileSize(GB) -> MB -> bytes
+	numBlocks := int(lunSizeBytes / blkSize)
+
+	cfgs := make([]spdkSubsystemConfig, 0, cfg.DeviceCount)
+	for i := 0; i < cfg.DeviceCount; i++ {
+		cfgs = append(cfgs, spdkSubsystemConfig{
+			Method: "bdev_malloc_create",
+			Params: mallocCreateParams{
+				Name:      bdevName("Malloc", cfg, i),
+				NumBlocks: numBlocks,
+				BlockSize: blkSize,
+			},
+		})
+	}
+
+	return cfgs
+}
+
 // ClassProvider implements functionality for a given bdev class
 type ClassProvider struct {
 	log     logging.Logger
@@ -176,9 +348,9 @@ func NewClassProvider(log logging.Logger, cfgDir string, cfg *storage.BdevTier)
 	for tierIdx, _ := range cfg.Tier {
 		switch cfg.Tier[tierIdx].Class {
 		case storage.BdevClassNone:
-			p.bdev = append(p.bdev, bdev{nvmeTempl, "", isEmptyList, isValidList, nilInit})
+			p.bdev = append(p.bdev, bdev{nvmeTempl, genNvmeJSON, "", isEmptyList, isValidList, nilInit})
 		case storage.BdevClassNvme:
-			p.bdev = append(p.bdev, bdev{nvmeTempl, "NVME", isEmptyList, isValidList, nilInit})
+			p.bdev = append(p.bdev, bdev{nvmeTempl, genNvmeJSON, "NVME", isEmptyList, isValidList, nilInit})
 			if !cfg.Tier[tierIdx].VmdDisabled {
 				p.bdev[tierIdx].templ = `[Vmd]
     Enable True
@@ -186,11 +358,11 @@ func NewClassProvider(log logging.Logger, cfgDir string, cfg *storage.BdevTier)
 ` + p.bdev[tierIdx].templ
 			}
 		case storage.BdevClassMalloc:
-			p.bdev = append(p.bdev, bdev{mallocTempl, "MALLOC", isEmptyNumber, nilValidate, nilInit})
+			p.bdev = append(p.bdev, bdev{mallocTempl, genMallocJSON, "MALLOC", isEmptyNumber, nilValidate, nilInit})
 		case storage.BdevClassKdev:
-			p.bdev = append(p.bdev, bdev{kdevTempl, "AIO", isEmptyList, isValidList, nilInit})
+			p.bdev = append(p.bdev, bdev{kdevTempl, genKdevJSON, "AIO", isEmptyList, isValidList, nilInit})
 		case storage.BdevClassFile:
-			p.bdev = append(p.bdev, bdev{fileTempl, "AIO", isEmptyList, isValidSize, bdevFileInit})
+			p.bdev = append(p.bdev, bdev{fileTempl, genFileJSON, "AIO", isEmptyList, isValidSize, bdevFileInit})
 		default:
 			return nil, errors.Errorf("unable to map %q to BdevClass", cfg.Tier[tierIdx].Class)
 		}
@@ -221,6 +393,13 @@ func NewClassProvider(log logging.Logger, cfgDir string, cfg *storage.BdevTier)
 	return p, nil
 }
 
+// isJSON reports whether the tier has opted into the SPDK JSON-RPC config
+// format via BdevTier.ConfigFormat. The zero value keeps emitting the
+// legacy daos_nvme.conf so existing deployments are unaffected by upgrade.
+func (p *ClassProvider) isJSON() bool {
+	return p.cfg.ConfigFormat == configFormatJSON
+}
+
 // GenConfigFile generates nvme config file for given bdev type to be consumed
 // by spdk.
 func (p *ClassProvider) GenConfigFile() error {
@@ -229,6 +408,17 @@ func (p *ClassProvider) GenConfigFile() error {
 
 		return nil
 	}
+
+	if p.isJSON() {
+		return p.genConfigFileJSON()
+	}
+
+	return p.genConfigFileLegacy()
+}
+
+// genConfigFileLegacy writes the concatenated legacy per-tier INI sections
+// consumed via "-c daos_nvme.conf".
+func (p *ClassProvider) genConfigFileLegacy() error {
 	f, err := os.Create(p.cfgPath) // @todo_llasek
 	defer func() {
 		ce := f.Close()
@@ -253,7 +443,7 @@ func (p *ClassProvider) GenConfigFile() error {
 			return errors.New("spdk: generated nvme config is unexpectedly empty")
 		}
 
-		p.log.Debugf("create %s with %v bdevs", p.cfgPath[tierIdx], p.cfg.Tier[tierIdx].DeviceList)
+		p.log.Debugf("create %s with %v bdevs", p.cfgPath, p.cfg.Tier[tierIdx].DeviceList)
 
 		if _, err := confBytes.WriteTo(f); err != nil {
 			return errors.Wrapf(err, "spdk: failed to write NVMe config to file %s", p.cfgPath)
@@ -261,3 +451,65 @@ func (p *ClassProvider) GenConfigFile() error {
 	}
 	return nil
 }
+
+// genConfigFileJSON writes a single SPDK JSON-RPC config-file document
+// containing the ordered method calls for every tier, consumed via
+// "--json-config". This replaces the brittle template string splicing of
+// genConfigFileLegacy with the schema SPDK itself uses for bdev_*.py RPCs.
+func (p *ClassProvider) genConfigFileJSON() error {
+	vmdCfgs := make([]spdkSubsystemConfig, 0, 1)
+	bdevCfgs := make([]spdkSubsystemConfig, 0)
+	nvmeOptionsSet := false
+
+	for tierIdx := range p.bdev {
+		tier := &p.cfg.Tier[tierIdx]
+
+		if err := p.bdev[tierIdx].init(p.log, tier); err != nil {
+			return errors.Wrap(err, "bdev device init")
+		}
+
+		if tier.Class == storage.BdevClassNvme && !tier.VmdDisabled {
+			vmdCfgs = append(vmdCfgs, spdkSubsystemConfig{
+				Method: "vmd_enable",
+				Params: vmdEnableParams{Enable: true},
+			})
+		}
+
+		// bdev_nvme_set_options is a global, once-only SPDK option; emit
+		// it from the first NVMe tier only, or a later tier's call would
+		// silently overwrite an earlier one's hotplug/retry settings.
+		if usesNvmeSetOptions(tier.Class) {
+			if !nvmeOptionsSet {
+				bdevCfgs = append(bdevCfgs, nvmeSetOptionsConfig(tier.Hotplug))
+				nvmeOptionsSet = true
+			} else if tier.Hotplug.Enable {
+				p.log.Debugf("spdk: bdev_nvme_set_options already emitted for this engine; tier %d's hotplug settings are ignored", tierIdx)
+			}
+		}
+
+		tierCfgs := p.bdev[tierIdx].genJSON(tier)
+		if len(tierCfgs) == 0 {
+			return errors.New("spdk: generated nvme config is unexpectedly empty")
+		}
+
+		p.log.Debugf("create %s with %v bdevs", p.cfgPath, tier.DeviceList)
+		bdevCfgs = append(bdevCfgs, tierCfgs...)
+	}
+
+	cfg := spdkConfig{}
+	if len(vmdCfgs) > 0 {
+		cfg.Subsystems = append(cfg.Subsystems, spdkSubsystem{Name: "vmd", Configs: vmdCfgs})
+	}
+	cfg.Subsystems = append(cfg.Subsystems, spdkSubsystem{Name: "bdev", Configs: bdevCfgs})
+
+	out, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "spdk: failed to marshal JSON-RPC bdev config")
+	}
+
+	if err := os.WriteFile(p.cfgPath, out, 0644); err != nil {
+		return errors.Wrapf(err, "spdk: failed to write NVMe config to file %s", p.cfgPath)
+	}
+
+	return nil
+}
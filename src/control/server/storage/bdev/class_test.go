@@ -0,0 +1,104 @@
+//
+// (C) Copyright 2021 Intel Corporation.
+//
+// SPDX-License-Identifier: BSD-2-Clause-Patent
+//
+
+package bdev
+
+import (
+	"testing"
+
+	"github.com/daos-stack/daos/src/control/server/storage"
+)
+
+func TestGenNvmeJSON(t *testing.T) {
+	cfg := &storage.BdevConfig{
+		Hostname:   "foohost",
+		TierIdx:    1,
+		DeviceList: []string{"0000:81:00.0", "0000:82:00.0"},
+	}
+
+	cfgs := genNvmeJSON(cfg)
+
+	// bdev_nvme_set_options is a document-wide option and is no longer
+	// part of genNvmeJSON's output; see nvmeSetOptionsConfig.
+	if len(cfgs) != 2 { // 2 attach_controller
+		t.Fatalf("expected 2 JSON-RPC calls, got %d", len(cfgs))
+	}
+	for i, c := range cfgs {
+		if c.Method != "bdev_nvme_attach_controller" {
+			t.Fatalf("expected bdev_nvme_attach_controller, got %s", c.Method)
+		}
+		params, ok := c.Params.(nvmeAttachControllerParams)
+		if !ok {
+			t.Fatalf("unexpected params type %T", c.Params)
+		}
+		wantName := bdevName("Nvme", cfg, i)
+		if params.Name != wantName {
+			t.Fatalf("expected name %s, got %s", wantName, params.Name)
+		}
+	}
+}
+
+func TestUsesNvmeSetOptions(t *testing.T) {
+	for _, tc := range []struct {
+		class storage.BdevClass
+		want  bool
+	}{
+		{storage.BdevClassNone, true},
+		{storage.BdevClassNvme, true},
+		{storage.BdevClassMalloc, false},
+		{storage.BdevClassKdev, false},
+		{storage.BdevClassFile, false},
+	} {
+		if got := usesNvmeSetOptions(tc.class); got != tc.want {
+			t.Errorf("class %q: expected usesNvmeSetOptions()=%v, got %v", tc.class, tc.want, got)
+		}
+	}
+}
+
+func TestGenMallocJSON(t *testing.T) {
+	cfg := &storage.BdevConfig{
+		Hostname:    "foohost",
+		TierIdx:     0,
+		DeviceCount: 2,
+		FileSize:    1, // 1GB -> 1000MB per lun
+	}
+
+	cfgs := genMallocJSON(cfg)
+
+	if len(cfgs) != cfg.DeviceCount {
+		t.Fatalf("expected %d malloc bdevs (one per DeviceCount), got %d", cfg.DeviceCount, len(cfgs))
+	}
+
+	wantNumBlocks := int((int64(cfg.FileSize) * 1000 * 1000000) / blkSize)
+	for i, c := range cfgs {
+		params, ok := c.Params.(mallocCreateParams)
+		if !ok {
+			t.Fatalf("unexpected params type %T", c.Params)
+		}
+		if params.NumBlocks != wantNumBlocks {
+			t.Fatalf("lun %d: expected %d blocks, got %d", i, wantNumBlocks, params.NumBlocks)
+		}
+		if params.BlockSize != blkSize {
+			t.Fatalf("lun %d: expected block size %d, got %d", i, blkSize, params.BlockSize)
+		}
+	}
+}
+
+func TestClassProviderIsJSON(t *testing.T) {
+	for _, tc := range []struct {
+		format string
+		want   bool
+	}{
+		{"", false},
+		{"legacy", false},
+		{"json", true},
+	} {
+		p := &ClassProvider{cfg: &storage.BdevTier{ConfigFormat: tc.format}}
+		if got := p.isJSON(); got != tc.want {
+			t.Errorf("ConfigFormat=%q: expected isJSON()=%v, got %v", tc.format, tc.want, got)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+//
+// (C) Copyright 2021 Intel Corporation.
+//
+// SPDX-License-Identifier: BSD-2-Clause-Patent
+//
+
+package bdev
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+
+	"github.com/pkg/errors"
+
+	"github.com/daos-stack/daos/src/control/logging"
+	"github.com/daos-stack/daos/src/control/server/storage"
+)
+
+// rpcRequest is a minimal JSON-RPC 2.0 request envelope matching the schema
+// SPDK's rpc.py speaks over its unix domain socket.
+type rpcRequest struct {
+	JSONRPC string      `json:"jsonrpc"`
+	ID      int         `json:"id"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params,omitempty"`
+}
+
+// rpcResponse is the corresponding reply envelope. Result is left as
+// json.RawMessage as callers only care whether Error is populated.
+type rpcResponse struct {
+	ID     int             `json:"id"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// callRPC issues a single JSON-RPC request against the SPDK unix domain
+// socket exposed by a running engine and returns an error if SPDK reports
+// one back.
+func callRPC(sockPath, method string, params interface{}) error {
+	conn, err := net.Dial("unix", sockPath)
+	if err != nil {
+		return errors.Wrapf(err, "connect to spdk rpc socket %s", sockPath)
+	}
+	defer conn.Close()
+
+	if err := json.NewEncoder(conn).Encode(rpcRequest{
+		JSONRPC: "2.0",
+		ID:      1,
+		Method:  method,
+		Params:  params,
+	}); err != nil {
+		return errors.Wrap(err, "encode spdk rpc request")
+	}
+
+	var resp rpcResponse
+	if err := json.NewDecoder(bufio.NewReader(conn)).Decode(&resp); err != nil {
+		return errors.Wrap(err, "decode spdk rpc response")
+	}
+	if resp.Error != nil {
+		return errors.Errorf("spdk rpc %s: %s (code %d)", method, resp.Error.Message, resp.Error.Code)
+	}
+
+	return nil
+}
+
+// AttachController issues a live bdev_nvme_attach_controller call against a
+// running engine's SPDK JSON-RPC socket, for a PCI address that was not part
+// of the config generated at engine start (i.e. a hotplug insertion).
+func AttachController(log logging.Logger, sockPath string, cfg *storage.BdevConfig, pciAddr string) error {
+	name := bdevName("Nvme", cfg, len(cfg.DeviceList))
+	log.Debugf("spdk: attaching hotplugged controller %s (%s) via %s", name, pciAddr, sockPath)
+
+	return callRPC(sockPath, "bdev_nvme_attach_controller", nvmeAttachControllerParams{
+		Name:             name,
+		TransportType:    "PCIe",
+		TransportAddress: pciAddr,
+	})
+}
+
+// PCIAddrForName returns the PCI address backing the SPDK bdev name within
+// cfg, the inverse of the naming AttachController derives, so a detach
+// request that only carries a bdev name (bdev_nvme_detach_controller doesn't
+// take a PCI address) can still be reported against a real PCI address.
+func PCIAddrForName(cfg *storage.BdevConfig, name string) (string, error) {
+	for i, addr := range cfg.DeviceList {
+		if bdevName("Nvme", cfg, i) == name {
+			return addr, nil
+		}
+	}
+
+	return "", errors.Errorf("%s: no matching device in tier %d's DeviceList", name, cfg.TierIdx)
+}
+
+// DetachController issues a live bdev_nvme_detach_controller call to remove
+// a controller that SPDK reported as hot-removed.
+func DetachController(log logging.Logger, sockPath, name string) error {
+	log.Debugf("spdk: detaching controller %s via %s", name, sockPath)
+
+	return callRPC(sockPath, "bdev_nvme_detach_controller", struct {
+		Name string `json:"name"`
+	}{Name: name})
+}
@@ -0,0 +1,129 @@
+//
+// (C) Copyright 2021 Intel Corporation.
+//
+// SPDX-License-Identifier: BSD-2-Clause-Patent
+//
+
+package bdev
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/daos-stack/daos/src/control/logging"
+)
+
+const pciDevicesDir = "/sys/bus/pci/devices"
+
+// SRIOVConfig describes a single physical function to be split into virtual
+// functions before SPDK attaches them, e.g. for multi-tenant engine layouts
+// where each engine gets its own VF of a shared NVMe-oF controller.
+//
+// ConfigureVFs only brings sriov_numvfs in line with NumVFs; it doesn't bind
+// the resulting VFs to a driver. That's delegated to the same allowlist-driven
+// bind step (honoring DisableVFIO) that binds every other configured PCI
+// device, since prepBdevStorage folds the discovered VF BDFs into
+// bdev.PrepareRequest.PCIAllowlist before the automatic prepare runs.
+type SRIOVConfig struct {
+	// PF is the PCI address (BDF) of the physical function.
+	PF string
+	// NumVFs is the desired virtual function count for PF.
+	NumVFs int
+	// EngineIdx is the index (within cfg.Engines) of the engine that
+	// should have PF's resulting VFs added to its bdev DeviceList.
+	EngineIdx int
+}
+
+func sriovAttrPath(pf, attr string) string {
+	return filepath.Join(pciDevicesDir, pf, attr)
+}
+
+// totalVFs returns the maximum number of VFs pf's device supports, read
+// from sriov_totalvfs.
+func totalVFs(pf string) (int, error) {
+	return readPCIInt(sriovAttrPath(pf, "sriov_totalvfs"))
+}
+
+// currentVFs returns the number of VFs currently enabled for pf, read from
+// sriov_numvfs.
+func currentVFs(pf string) (int, error) {
+	return readPCIInt(sriovAttrPath(pf, "sriov_numvfs"))
+}
+
+func readPCIInt(path string) (int, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, errors.Wrapf(err, "read %s", path)
+	}
+
+	n, err := strconv.Atoi(strings.TrimSpace(string(b)))
+	if err != nil {
+		return 0, errors.Wrapf(err, "parse %s", path)
+	}
+
+	return n, nil
+}
+
+// ConfigureVFs brings pf's sriov_numvfs in line with cfg.NumVFs, echoing 0
+// first if VFs are already enabled since the kernel refuses to change a
+// nonzero count directly. It is a no-op if the current count already
+// matches.
+func ConfigureVFs(log logging.Logger, cfg *SRIOVConfig) error {
+	total, err := totalVFs(cfg.PF)
+	if err != nil {
+		return err
+	}
+	if cfg.NumVFs > total {
+		return errors.Errorf("sriov: %s supports at most %d VFs, %d requested", cfg.PF, total, cfg.NumVFs)
+	}
+
+	current, err := currentVFs(cfg.PF)
+	if err != nil {
+		return err
+	}
+	if current == cfg.NumVFs {
+		log.Debugf("sriov: %s already has %d VFs enabled", cfg.PF, current)
+		return nil
+	}
+
+	numVFsPath := sriovAttrPath(cfg.PF, "sriov_numvfs")
+	if current != 0 {
+		log.Debugf("sriov: disabling %d existing VFs on %s before reconfiguring", current, cfg.PF)
+		if err := ioutil.WriteFile(numVFsPath, []byte("0"), 0200); err != nil {
+			return errors.Wrapf(err, "disable existing VFs on %s", cfg.PF)
+		}
+	}
+
+	log.Debugf("sriov: enabling %d VFs on %s", cfg.NumVFs, cfg.PF)
+	if err := ioutil.WriteFile(numVFsPath, []byte(strconv.Itoa(cfg.NumVFs)), 0200); err != nil {
+		return errors.Wrapf(err, "enable %d VFs on %s", cfg.NumVFs, cfg.PF)
+	}
+
+	return nil
+}
+
+// VFAddresses returns the PCI addresses (BDFs) of the virtual functions
+// currently instantiated under pf, in index order (virtfn0, virtfn1, ...).
+func VFAddresses(pf string) ([]string, error) {
+	numVFs, err := currentVFs(pf)
+	if err != nil {
+		return nil, err
+	}
+
+	addrs := make([]string, 0, numVFs)
+	for i := 0; i < numVFs; i++ {
+		link := sriovAttrPath(pf, fmt.Sprintf("virtfn%d", i))
+		target, err := filepath.EvalSymlinks(link)
+		if err != nil {
+			return nil, errors.Wrapf(err, "resolve %s", link)
+		}
+		addrs = append(addrs, filepath.Base(target))
+	}
+
+	return addrs, nil
+}
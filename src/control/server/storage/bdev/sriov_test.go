@@ -0,0 +1,41 @@
+//
+// (C) Copyright 2021 Intel Corporation.
+//
+// SPDX-License-Identifier: BSD-2-Clause-Patent
+//
+
+package bdev
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadPCIInt(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "sriov_totalvfs")
+	if err := ioutil.WriteFile(path, []byte("32\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := readPCIInt(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != 32 {
+		t.Fatalf("got %d, want 32", got)
+	}
+
+	if _, err := readPCIInt(filepath.Join(dir, "missing")); err == nil {
+		t.Fatal("expected error for missing file")
+	}
+}
+
+func TestSRIOVConfigEngineIdxDefault(t *testing.T) {
+	cfg := SRIOVConfig{PF: "0000:81:00.0", NumVFs: 2}
+
+	if cfg.EngineIdx != 0 {
+		t.Fatalf("expected zero-value EngineIdx to default to engine 0, got %d", cfg.EngineIdx)
+	}
+}
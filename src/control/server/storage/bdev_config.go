@@ -0,0 +1,73 @@
+//
+// (C) Copyright 2021 Intel Corporation.
+//
+// SPDX-License-Identifier: BSD-2-Clause-Patent
+//
+
+package storage
+
+// BdevClass identifies which SPDK bdev module backs a storage tier.
+type BdevClass string
+
+const (
+	// BdevClassNone is the zero value. It generates the same NVMe bdev
+	// config as BdevClassNvme, but sets no VOS env and never enables VMD.
+	BdevClassNone BdevClass = ""
+	// BdevClassNvme backs a tier with physical (or VMD/SR-IOV VF) NVMe
+	// controllers attached by PCI address.
+	BdevClassNvme BdevClass = "nvme"
+	// BdevClassMalloc backs a tier with in-memory SPDK malloc bdevs,
+	// primarily for testing.
+	BdevClassMalloc BdevClass = "malloc"
+	// BdevClassKdev backs a tier with SPDK AIO bdevs over kernel block
+	// devices.
+	BdevClassKdev BdevClass = "kdev"
+	// BdevClassFile backs a tier with SPDK AIO bdevs over emulated files.
+	BdevClassFile BdevClass = "file"
+)
+
+// BdevHotplugConfig controls whether SPDK's periodic NVMe hotplug poller is
+// armed for a tier, and which PCI addresses are permitted to attach at
+// runtime via BdevAttach.
+type BdevHotplugConfig struct {
+	// Enable arms the periodic hotplug poller for the tier.
+	Enable bool
+	// PollRateUsec is the poller interval. SPDK defaults apply when 0.
+	PollRateUsec uint32
+	// AllowList restricts which PCI addresses may be attached via
+	// hotplug; an empty list allows any address.
+	AllowList []string
+}
+
+// BdevConfig describes a single storage tier's bdev devices and the
+// behaviors SPDK should apply to them.
+type BdevConfig struct {
+	Hostname    string
+	TierIdx     int
+	Class       BdevClass
+	DeviceList  []string
+	DeviceCount int
+	FileSize    int
+	VmdDisabled bool
+	VosEnv      string
+	Hotplug     BdevHotplugConfig
+}
+
+// BdevTier groups the per-tier bdev configs for a single engine, along with
+// provider-assigned bookkeeping (the generated config path and tier count)
+// and the knob controlling which SPDK config-file format ClassProvider
+// emits.
+type BdevTier struct {
+	Tier []BdevConfig
+	// ConfigPath is set by ClassProvider once the config file location
+	// is known.
+	ConfigPath string
+	// TiersNum is set by ClassProvider to len(Tier).
+	TiersNum int
+	// ConfigFormat selects the SPDK config-file schema ClassProvider
+	// emits: "legacy" (the default, INI-style daos_nvme.conf consumed
+	// via "-c") or "json" (SPDK's JSON-RPC config-file schema, consumed
+	// via "--json-config"). Operators can stage a migration by flipping
+	// this per engine.
+	ConfigFormat string
+}